@@ -0,0 +1,145 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// configChecker collects the problems found by config check.
+type configChecker struct {
+	problems int
+}
+
+// report prints the problems of err, one per line.
+func (checker *configChecker) report(err error) {
+	if errs, ok := err.(config.Errors); ok {
+		for _, err := range errs {
+			fmt.Println("  FAIL ", err)
+		}
+		checker.problems += len(errs)
+		return
+	}
+	fmt.Println("  FAIL ", err)
+	checker.problems++
+}
+
+// checkConfiguration validates the cPanel and Storj configuration strictly and,
+// unless --offline is given, checks the cPanel credentials and the access to the bucket.
+// Every problem found is reported before it returns.
+func checkConfiguration(cliContext *cli.Context) error {
+	var checker configChecker
+	var configcPanel cpanel.ConfigcPanel
+	var configStorj storj.ConfigStorj
+	var cpanelValid, storjValid bool
+	args := cliContext.Args().Slice()
+	var keyValue string
+
+	if cliContext.IsSet("profile") || cliContext.IsSet("config") {
+		fmt.Println("Checking ", cliContext.String("config"))
+		file, err := config.Load(cliContext.String("config"))
+		if err == nil {
+			var profile config.Profile
+			profile, err = file.Profile(cliContext.String("profile"))
+			configcPanel, configStorj = profile.CPanel, profile.Storj
+		}
+		if err != nil {
+			checker.report(err)
+		} else {
+			fmt.Println("  OK")
+			cpanelValid, storjValid = true, true
+		}
+		if len(args) > 0 {
+			keyValue = args[0]
+		}
+	} else {
+		fullFileNamecPanel, fullFileNameStorj := cpanelConfigFile, storjConfigFile
+		if len(args) > 0 {
+			fullFileNamecPanel = args[0]
+		}
+		if len(args) > 1 {
+			fullFileNameStorj = args[1]
+		}
+		if len(args) > 2 {
+			keyValue = args[2]
+		}
+
+		var err error
+		fmt.Println("Checking ", fullFileNamecPanel)
+		configcPanel, err = config.LoadCPanel(fullFileNamecPanel)
+		if err != nil {
+			checker.report(err)
+		} else {
+			fmt.Println("  OK")
+			cpanelValid = true
+		}
+
+		fmt.Println("Checking ", fullFileNameStorj)
+		configStorj, err = config.LoadStorj(fullFileNameStorj)
+		if err != nil {
+			checker.report(err)
+		} else {
+			fmt.Println("  OK")
+			storjValid = true
+		}
+	}
+
+	if path := cliContext.String("notify"); cliContext.IsSet("notify") || fileExists(path) {
+		fmt.Println("Checking ", path)
+		if _, err := config.LoadNotifications(path); err != nil {
+			checker.report(err)
+		} else {
+			fmt.Println("  OK")
+		}
+	}
+
+	if !cliContext.Bool("offline") {
+		if cpanelValid {
+			fmt.Printf("Checking cPanel credentials of %s@%s\n", configcPanel.UserName, configcPanel.HostName)
+			quota, err := cpanel.CheckConnection(configcPanel)
+			if err != nil {
+				checker.report(err)
+			} else if quota.Unlimited() {
+				fmt.Println("  OK, no disk quota")
+			} else {
+				fmt.Printf("  OK, %.0f of %.0f MB disk quota used\n", quota.MegabytesUsed, quota.MegabyteLimit)
+			}
+		}
+
+		if storjValid {
+			configStorj = configStorj.ForAccount(configcPanel.UserName)
+			fmt.Println("Checking access to bucket ", configStorj.Bucket)
+			exists, err := storj.CheckConnection(context.Background(), configStorj, keyValue)
+			switch {
+			case err != nil:
+				checker.report(err)
+			case exists:
+				fmt.Println("  OK")
+			default:
+				fmt.Println("  OK, the bucket does not exist yet and is created by the first upload")
+			}
+		}
+	}
+
+	fmt.Println(" ")
+	if checker.problems > 0 {
+		return fmt.Errorf("%d problem(s) found", checker.problems)
+	}
+	fmt.Println("Configuration OK")
+	return nil
+}
+
+// fileExists reports whether there is a file at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
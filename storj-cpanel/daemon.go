@@ -0,0 +1,190 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/metrics"
+	"utropicmedia/cpanel_storj_interface/state"
+
+	"github.com/urfave/cli"
+)
+
+// defaultRetryAfter is how long the daemon waits before it retries a failed backup.
+const defaultRetryAfter = time.Hour
+
+// daemonFlags configure the daemon command.
+var daemonFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "listen",
+		Value: ":9180",
+		Usage: "serve the metrics at http://`ADDRESS`/metrics; empty disables the endpoint",
+	},
+	&cli.DurationFlag{
+		Name:  "retry-after",
+		Value: defaultRetryAfter,
+		Usage: "wait this long before retrying a failed backup",
+	},
+	configFlag,
+	stateFlag,
+	scopeLogFlag,
+	bwlimitFlag,
+	notifyFlag,
+	lockDirFlag,
+	lockWaitFlag,
+}
+
+// scheduledProfile is a profile the daemon backs up on its schedule.
+type scheduledProfile struct {
+	profile config.Profile
+	// lastAttempt is when the daemon last started a backup of the profile.
+	lastAttempt time.Time
+}
+
+// runDaemon backs up every profile of the configuration file that has a schedule whenever a backup is due,
+// and serves the metrics of the runs. Positional arguments key and restrict are used as with store.
+// It returns when interrupted, after the current run has stopped.
+func runDaemon(cliContext *cli.Context) error {
+	file, err := config.Load(cliContext.String("config"))
+	if err != nil {
+		return err
+	}
+	var profiles []*scheduledProfile
+	for _, name := range file.ProfileNames() {
+		if profile := file.Profiles[name]; !profile.Schedule.IsZero() {
+			profiles = append(profiles, &scheduledProfile{profile: profile})
+		}
+	}
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profile of %s has a schedule", cliContext.String("config"))
+	}
+
+	notifications, err := loadNotifications(cliContext)
+	if err != nil {
+		return err
+	}
+	bandwidth, err := bandwidthOverride(cliContext)
+	if err != nil {
+		return err
+	}
+
+	registry := newMetricsRegistry()
+	if address := cliContext.String("listen"); address != "" {
+		if err := serveMetrics(address, registry); err != nil {
+			return err
+		}
+	}
+
+	ctx := cliContext.Context
+	args := cliContext.Args().Slice()
+	for {
+		due, next, err := nextBackup(cliContext, profiles, registry)
+		if err != nil {
+			return err
+		}
+		logger.Info("Next backup", logging.F("profile", due.profile.Name), logging.F("due", next))
+		if !sleepUntil(ctx, next) {
+			logger.Info("Daemon stopped")
+			return nil
+		}
+
+		due.lastAttempt = time.Now()
+		startJob()
+		report := newRunReport("store")
+		report.Host, report.Account = due.profile.CPanel.HostName, due.profile.CPanel.UserName
+		conf := configuration{
+			profile: &due.profile,
+			cpanel:  due.profile.CPanel,
+			storj:   due.profile.Storj,
+			args:    args,
+			dir:     filepath.Dir(cliContext.String("config")),
+		}
+		conf.limitBandwidth(bandwidth)
+
+		err = runStore(ctx, conf, storeOptions{
+			keyValue:    conf.arg(0),
+			restrict:    conf.arg(1),
+			state:       cliContext.String("state"),
+			scopeLog:    cliContext.String("scope-log"),
+			lockDir:     lockDirectory(cliContext, conf),
+			lockWait:    cliContext.Duration("lock-wait"),
+			countBucket: true,
+		}, report)
+		if err != nil {
+			logger.Error("Backup failed", logging.F("profile", due.profile.Name), logging.F("error", err))
+		}
+		_ = report.finish(cliContext, err)
+		notifyRun(notifications, report)
+		recordMetrics(registry, report)
+		countMetrics(registry, report)
+	}
+}
+
+// sleepUntil waits until t and reports whether it did, or false when ctx was done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextBackup returns the profile whose backup is due first and when it is due.
+// A profile is due when its schedule says so after the last successful upload of the account,
+// but not before retry-after has passed since the last attempt.
+func nextBackup(cliContext *cli.Context, profiles []*scheduledProfile, registry *metrics.Registry) (*scheduledProfile, time.Time, error) {
+	// The upload history is read again for every backup, as the runs record their uploads in the file.
+	history, err := state.Open(cliContext.String("state"))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var due *scheduledProfile
+	var next time.Time
+	for _, scheduled := range profiles {
+		configcPanel := scheduled.profile.CPanel
+		at := time.Now()
+		if last, ok := history.LastSuccess(configcPanel.HostName, configcPanel.UserName); ok {
+			at = scheduled.profile.Schedule.Next(last.Finished)
+			// Alerts on the age of backups keep working when the daemon restarts.
+			registry.Set(metricLastSuccess, accountLabels(configcPanel.HostName, configcPanel.UserName), float64(last.Finished.Unix()))
+		}
+		if retry := scheduled.lastAttempt.Add(cliContext.Duration("retry-after")); retry.After(at) {
+			at = retry
+		}
+		if due == nil || at.Before(next) {
+			due, next = scheduled, at
+		}
+	}
+	return due, next, nil
+}
+
+// serveMetrics serves the metrics of registry at address in the background.
+func serveMetrics(address string, registry *metrics.Registry) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics endpoint failed", logging.F("error", err))
+		}
+	}()
+	logger.Info("Serving metrics", logging.F("address", listener.Addr().String()))
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/state"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// dryRunFlag shows what a run would do without changing anything.
+var dryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "check the configuration and connections and print what the run would do, without generating a backup, uploading or deleting anything",
+}
+
+// planStore prints what runStore would upload with options, checking the cPanel login and the
+// access to the bucket on the way. It neither generates a backup nor uploads, creates the bucket,
+// runs hooks or records anything.
+func planStore(ctx context.Context, conf configuration, options storeOptions, history *state.Store, report *runReport) error {
+	configcPanel := conf.cpanel
+	report.Stage = stageCPanel
+	var plans []cpanel.BackupPlan
+	if options.allPending {
+		backups, err := cpanel.LocalBackupsWithConfig(configcPanel)
+		if err != nil {
+			return err
+		}
+		for _, backup := range backups {
+			size, err := cpanel.BackupSize(configcPanel, backup)
+			if err != nil {
+				return err
+			}
+			plans = append(plans, cpanel.BackupPlan{Backup: backup, Size: size})
+		}
+	} else {
+		plan, err := cpanel.PlanBackup(configcPanel, options.backup)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	configStorj := conf.storj.ForAccount(configcPanel.UserName)
+
+	report.Stage = stageStorj
+	session, err := storj.OpenSessionReadOnly(ctx, configStorj, options.keyValue, options.restrict)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Like storePending, --all-pending also skips the backups found in the bucket.
+	uploaded := make(map[string]bool)
+	if options.allPending {
+		objects, err := session.List(ctx, configStorj.AccountPrefix(configcPanel.HostName, configcPanel.UserName))
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			uploaded[object.Key] = true
+		}
+	}
+
+	fmt.Fprintln(output, " ")
+	fmt.Fprintln(output, "Dry run: no backup is generated, uploaded or recorded.")
+	if !session.BucketExists() {
+		fmt.Fprintf(output, "Bucket %s does not exist yet and would be created.\n", configStorj.Bucket)
+	}
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ACTION\tFILE\tBACKUP DATE\tSIZE\tBUCKET\tOBJECT KEY")
+	for _, plan := range plans {
+		backup := plan.Backup
+		key := session.ObjectKey(storj.KeyFields{
+			Host:     configcPanel.HostName,
+			Account:  configcPanel.UserName,
+			Type:     storj.BackupTypeFull,
+			FileName: backup.File,
+			Time:     backup.Time,
+		})
+		_, inHistory := history.Uploaded(configcPanel.HostName, configcPanel.UserName, backup.File, plan.Size)
+
+		action, size := "upload", fmt.Sprint(plan.Size)
+		switch {
+		case plan.Generate:
+			action, size = "generate and upload", "-"
+			if plan.Space != nil {
+				size = fmt.Sprintf("~%d", plan.Size)
+			}
+			report.planned(configcPanel, plan, configStorj.Bucket, key)
+		case (uploaded[key] || inHistory) && !options.force:
+			action = "skip, already uploaded"
+			report.skippedBackup(configcPanel, backup, configStorj.Bucket, key)
+		default:
+			report.planned(configcPanel, plan, configStorj.Bucket, key)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			action, backup.File, backup.Time.Format(time.RFC3339), size, configStorj.Bucket, key)
+	}
+	if plans == nil {
+		fmt.Fprintln(writer, "none\t-\t-\t-\t-\t-")
+	}
+	return writer.Flush()
+}
+
+// printPrunePlan prints which backups prune would delete and which it keeps.
+func printPrunePlan(backups, expired []storj.Object) error {
+	deleted := make(map[string]bool, len(expired))
+	for _, object := range expired {
+		deleted[object.Key] = true
+	}
+
+	fmt.Fprintln(output, " ")
+	fmt.Fprintln(output, "Dry run: no backup is deleted.")
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ACTION\tUPLOADED\tSIZE\tOBJECT KEY")
+	for _, object := range backups {
+		action := "keep"
+		if deleted[object.Key] {
+			action = "delete"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", action, object.Created.Format(time.RFC3339), object.Size, object.Key)
+	}
+	return writer.Flush()
+}
+
+// printGetPlan prints which object get would download to outputPath, and whether it would resume
+// an interrupted download, without writing anything.
+func printGetPlan(ctx context.Context, session *storj.Session, key, outputPath string, force bool) error {
+	info, err := session.StatDownload(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	action, destination := "download", outputPath
+	if outputPath == "-" {
+		destination = "standard output"
+	} else {
+		if _, err := os.Stat(outputPath); err == nil {
+			if !force {
+				return fmt.Errorf("%s exists, use --force to replace it", outputPath)
+			}
+			action = "download and replace"
+		}
+		if partial, err := os.Stat(outputPath + partialSuffix); err == nil && partial.Size() > 0 {
+			action = fmt.Sprintf("resume at byte %d", partial.Size())
+		}
+	}
+	size, parts, hash := "-", "-", "none stored, not verified"
+	if info.Size >= 0 {
+		size = fmt.Sprint(info.Size)
+	}
+	if info.Parts > 0 {
+		parts = fmt.Sprint(info.Parts)
+	}
+	if info.SHA256 != "" {
+		hash = info.SHA256
+	}
+
+	fmt.Fprintln(output, " ")
+	fmt.Fprintln(output, "Dry run: nothing is downloaded or written.")
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ACTION\tOBJECT KEY\tSIZE\tPARTS\tSHA256\tDESTINATION")
+	fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", action, key, size, parts, hash, destination)
+	return writer.Flush()
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// partialSuffix is appended to the file name of a download until it is complete and verified.
+const partialSuffix = ".part"
+
+// getFlags configure the get command.
+var getFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "object",
+		Usage: "download the object with key `KEY` (default: the latest backup of the account)",
+	},
+	&cli.StringFlag{
+		Name:  "account",
+		Usage: "download the latest backup of cPanel account `NAME` of the configured host instead of the configured account",
+	},
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "write the backup to `FILE`, or to the standard output with - (default: its file name in the current directory)",
+	},
+	&cli.BoolFlag{
+		Name:  "force",
+		Usage: "replace the output file if it exists",
+	},
+	configFlag,
+	profileFlag,
+	bwlimitFlag,
+	dryRunFlag,
+}
+
+// runGet downloads a backup from the bucket, by key or the latest backup of an account,
+// to a file or the standard output, and verifies it against the hash stored with it.
+func runGet(cliContext *cli.Context) error {
+	outputPath := cliContext.String("output")
+	if outputPath == "-" {
+		// The backup is written to the standard output, everything else to the standard error.
+		logToStderr()
+		output = os.Stderr
+	}
+
+	conf, err := loadConfiguration(cliContext, "cpanel", "storj")
+	if err != nil {
+		return err
+	}
+	bandwidth, err := bandwidthOverride(cliContext)
+	if err != nil {
+		return err
+	}
+	conf.limitBandwidth(bandwidth)
+
+	account := conf.cpanel.UserName
+	if cliContext.IsSet("account") {
+		account = cliContext.String("account")
+	}
+	configStorj := conf.storj.ForAccount(account)
+
+	ctx := cliContext.Context
+	session, err := storj.OpenSessionReadOnly(ctx, configStorj, conf.arg(0), "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	key := cliContext.String("object")
+	if key == "" {
+		latest, err := session.LatestBackup(ctx, conf.cpanel.HostName, account)
+		if err != nil {
+			return err
+		}
+		logger.Info("Latest backup", logging.F("account", account), logging.F("key", latest.Key), logging.F("uploaded", latest.Created))
+		key = latest.Key
+	}
+	if outputPath == "" {
+		outputPath = path.Base(key)
+	}
+
+	if cliContext.Bool("dry-run") {
+		return printGetPlan(ctx, session, key, outputPath, cliContext.Bool("force"))
+	}
+	if outputPath == "-" {
+		_, err := session.DownloadObject(ctx, key, os.Stdout, nil)
+		return err
+	}
+	return downloadToFile(ctx, session, key, outputPath, cliContext.Bool("force"))
+}
+
+// downloadToFile downloads the object with the given key to the file at name. The object is written
+// to name followed by partialSuffix first, which a later download of the same file resumes when
+// the download is interrupted, and renamed once it is complete and verified.
+func downloadToFile(ctx context.Context, session *storj.Session, key, name string, force bool) error {
+	if _, err := os.Stat(name); err == nil && !force {
+		return fmt.Errorf("%s exists, use --force to replace it", name)
+	}
+
+	partialName := name + partialSuffix
+	file, err := os.OpenFile(partialName, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	// Reading the partial download leaves the file at its end, where the rest of the object is written.
+	var partial io.Reader
+	if info.Size() > 0 {
+		logger.Info("Resuming download", logging.F("file", partialName), logging.F("bytes", info.Size()))
+		partial = file
+	}
+
+	result, err := session.DownloadObject(ctx, key, file, partial)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var checksum *storj.ChecksumError
+		if errors.As(err, &checksum) {
+			// Resuming a corrupt download cannot repair it.
+			_ = os.Remove(partialName)
+		}
+		return err
+	}
+	if err := os.Rename(partialName, name); err != nil {
+		return err
+	}
+
+	verified := "not verified, no hash is stored with the object"
+	if result.Verified {
+		verified = "verified"
+	}
+	fmt.Printf("Downloaded %s to %s (%d bytes, sha256 %s, %s)\n", key, name, result.Resumed+result.Bytes, result.SHA256, verified)
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/hooks"
+)
+
+// stageHook is the stage of a run that failed because a hook failed it.
+const stageHook = "hook"
+
+// storeHooks runs the hooks of the profile of a run of store, describing the run to them.
+type storeHooks struct {
+	// ctx stops the hooks when the run is interrupted; the onFailure hooks run regardless.
+	ctx    context.Context
+	config hooks.Config
+	env    hooks.Env
+	report *runReport
+}
+
+// newStoreHooks returns the hooks of the profile of conf, if any, for the run of report.
+func newStoreHooks(ctx context.Context, conf configuration, report *runReport) *storeHooks {
+	h := &storeHooks{
+		ctx: ctx,
+		env: hooks.Env{
+			Job:     report.Job,
+			Host:    conf.cpanel.HostName,
+			Account: conf.cpanel.UserName,
+			Status:  "running",
+		},
+		report: report,
+	}
+	if conf.profile != nil {
+		h.config = conf.profile.Hooks
+		h.env.Profile = conf.profile.Name
+	}
+	return h
+}
+
+func (h *storeHooks) run(stage string) error {
+	if h.config.IsZero() {
+		return nil
+	}
+	h.report.Stage = stageHook
+	return h.config.Run(h.ctx, stage, h.env)
+}
+
+// preBackup runs the hooks before cPanel generates or selects the backup.
+func (h *storeHooks) preBackup() error {
+	return h.run(hooks.PreBackup)
+}
+
+// postBackup runs the hooks when the backup of cpanelReader is ready to be uploaded.
+func (h *storeHooks) postBackup(cpanelReader *cpanel.Cpaneldata) error {
+	h.env.FileName, h.env.Bytes = cpanelReader.FileName, cpanelReader.Size
+	h.env.Bucket, h.env.ObjectKey, h.env.SHA256 = "", "", ""
+	return h.run(hooks.PostBackup)
+}
+
+// postUpload runs the hooks after the backup last added to the report was uploaded.
+func (h *storeHooks) postUpload() error {
+	if len(h.report.Backups) > 0 {
+		backup := h.report.Backups[len(h.report.Backups)-1]
+		h.env.Bucket, h.env.ObjectKey, h.env.SHA256 = backup.Bucket, backup.ObjectKey, backup.SHA256
+		h.env.Bytes = backup.Bytes
+	}
+	h.env.Status = resultSuccess
+	defer func() { h.env.Status = "running" }()
+	return h.run(hooks.PostUpload)
+}
+
+// failed runs the hooks for the run failing with err in the current stage of the report.
+// Their failures are only logged.
+func (h *storeHooks) failed(err error) {
+	h.env.Status, h.env.Stage, h.env.Error = resultFailed, h.report.Stage, err.Error()
+	if h.ctx.Err() != nil {
+		h.env.Status = resultInterrupted
+	}
+	// The report keeps the stage that failed.
+	_ = h.config.Run(context.Background(), hooks.OnFailure, h.env)
+}
@@ -0,0 +1,374 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultSatellite is suggested by init.
+const defaultSatellite = "us-central-1.tardigrade.io:7777"
+
+// prompter asks the questions of init on the terminal.
+type prompter struct {
+	reader *bufio.Reader
+}
+
+func newPrompter() *prompter {
+	return &prompter{reader: bufio.NewReader(os.Stdin)}
+}
+
+// ask prompts for a value; an empty answer selects defaultValue.
+func (p *prompter) ask(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	line, err := p.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// secret prompts for a password without echoing it; an empty answer selects defaultValue.
+func (p *prompter) secret(question, defaultValue string) (string, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		answer, err := p.ask(question, "")
+		if answer == "" {
+			answer = defaultValue
+		}
+		return answer, err
+	}
+
+	if defaultValue != "" {
+		fmt.Printf("%s [unchanged]: ", question)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(secret) == 0 {
+		return defaultValue, nil
+	}
+	return string(secret), nil
+}
+
+// confirm asks a yes or no question.
+func (p *prompter) confirm(question string, defaultYes bool) (bool, error) {
+	defaultValue := "y/N"
+	if defaultYes {
+		defaultValue = "Y/n"
+	}
+	answer, err := p.ask(question, defaultValue)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return defaultYes, nil
+}
+
+// initConfiguration interactively creates the cPanel and Storj configuration,
+// either as separate configuration files or as a profile of the configuration file.
+func initConfiguration(cliContext *cli.Context) error {
+	p := newPrompter()
+	offline := cliContext.Bool("offline")
+
+	fmt.Println("This wizard creates the configuration to back up a cPanel account to a Storj bucket.")
+	fmt.Println("Every answer is checked before the configuration is written; press Enter to keep the value in brackets.")
+	fmt.Println(" ")
+
+	configcPanel, err := askcPanel(p, offline)
+	if err != nil {
+		return err
+	}
+	fmt.Println(" ")
+	configStorj, err := askStorj(p, configcPanel.UserName, offline)
+	if err != nil {
+		return err
+	}
+	fmt.Println(" ")
+
+	cpanelFields := map[string]interface{}{
+		"hostname": configcPanel.HostName,
+		"username": configcPanel.UserName,
+		"password": configcPanel.Password,
+	}
+	storjFields := map[string]interface{}{
+		"bucketName": configStorj.Bucket,
+	}
+	for field, value := range map[string]string{
+		"uploadPath":           configStorj.UploadPath,
+		"accessGrant":          configStorj.AccessGrant,
+		"apikey":               configStorj.APIKey,
+		"satelliteURL":         configStorj.Satellite,
+		"encryptionpassphrase": configStorj.EncryptionPassphrase,
+	} {
+		if value != "" {
+			storjFields[field] = value
+		}
+	}
+
+	if cliContext.IsSet("profile") || cliContext.IsSet("config") {
+		name := cliContext.String("profile")
+		if name == "" {
+			name = configcPanel.UserName
+		}
+		return writeProfile(p, cliContext.String("config"), name, map[string]interface{}{
+			"cpanel": cpanelFields,
+			"storj":  storjFields,
+		})
+	}
+
+	fullFileNamecPanel, fullFileNameStorj := cpanelConfigFile, storjConfigFile
+	if cliContext.Args().Len() > 0 {
+		fullFileNamecPanel = cliContext.Args().Get(0)
+	}
+	if cliContext.Args().Len() > 1 {
+		fullFileNameStorj = cliContext.Args().Get(1)
+	}
+	for _, file := range []struct {
+		path   string
+		fields map[string]interface{}
+	}{
+		{fullFileNamecPanel, cpanelFields},
+		{fullFileNameStorj, storjFields},
+	} {
+		if _, err := os.Stat(file.path); err == nil {
+			overwrite, err := p.confirm(file.path+" exists, overwrite it?", false)
+			if err != nil {
+				return err
+			}
+			if !overwrite {
+				fmt.Println("Skipped ", file.path)
+				continue
+			}
+		}
+		if err := writeConfigFile(file.path, file.fields); err != nil {
+			return err
+		}
+		fmt.Println("Written ", file.path)
+	}
+	return nil
+}
+
+// askcPanel prompts for the cPanel host and credentials until they are valid
+// and, unless offline, cPanel accepts them.
+func askcPanel(p *prompter, offline bool) (cpanel.ConfigcPanel, error) {
+	var configcPanel cpanel.ConfigcPanel
+	for {
+		var err error
+		fmt.Println("cPanel account to back up")
+		if configcPanel.HostName, err = p.ask("  Host name (e.g. cpanel.example.com)", configcPanel.HostName); err != nil {
+			return configcPanel, err
+		}
+		if configcPanel.UserName, err = p.ask("  User name", configcPanel.UserName); err != nil {
+			return configcPanel, err
+		}
+		if configcPanel.Password, err = p.secret("  Password", configcPanel.Password); err != nil {
+			return configcPanel, err
+		}
+
+		problems := configcPanel.Check()
+		for _, problem := range problems {
+			fmt.Println("  FAIL ", problem)
+		}
+		if len(problems) == 0 {
+			if offline {
+				return configcPanel, nil
+			}
+			_, err = cpanel.CheckConnection(configcPanel)
+			if err == nil {
+				fmt.Println("  OK, logged in to cPanel")
+				return configcPanel, nil
+			}
+			fmt.Println("  FAIL ", err)
+		}
+
+		retry, err := p.confirm("Enter the cPanel settings again?", true)
+		if err != nil {
+			return configcPanel, err
+		}
+		if !retry {
+			return configcPanel, errors.New("no valid cPanel configuration entered")
+		}
+	}
+}
+
+// askStorj prompts for the access to the Storj bucket until it is valid and,
+// unless offline, the bucket can be accessed. With an API key the access grant is derived from it.
+func askStorj(p *prompter, account string, offline bool) (storj.ConfigStorj, error) {
+	ctx := context.Background()
+	configStorj := storj.ConfigStorj{Satellite: defaultSatellite, Bucket: "cpanel-backups"}
+	method := "key"
+	for {
+		var err error
+		fmt.Println("Storj bucket to store the backups in")
+		if method, err = p.ask("  Access the bucket with an API key or an existing access grant? (key/grant)", method); err != nil {
+			return configStorj, err
+		}
+
+		switch method {
+		case "grant":
+			configStorj.APIKey, configStorj.EncryptionPassphrase, configStorj.Satellite = "", "", ""
+			if configStorj.AccessGrant, err = p.ask("  Access grant", configStorj.AccessGrant); err != nil {
+				return configStorj, err
+			}
+		case "key":
+			configStorj.AccessGrant = ""
+			if configStorj.Satellite == "" {
+				configStorj.Satellite = defaultSatellite
+			}
+			if configStorj.Satellite, err = p.ask("  Satellite address", configStorj.Satellite); err != nil {
+				return configStorj, err
+			}
+			if configStorj.APIKey, err = p.ask("  API key created in the satellite web interface", configStorj.APIKey); err != nil {
+				return configStorj, err
+			}
+			if configStorj.EncryptionPassphrase, err = p.secret("  Encryption passphrase", configStorj.EncryptionPassphrase); err != nil {
+				return configStorj, err
+			}
+		default:
+			fmt.Println("  FAIL  expected key or grant")
+			method = "key"
+			continue
+		}
+		if configStorj.Bucket, err = p.ask("  Bucket name", configStorj.Bucket); err != nil {
+			return configStorj, err
+		}
+		if configStorj.UploadPath, err = p.ask("  Upload path in the bucket (optional)", configStorj.UploadPath); err != nil {
+			return configStorj, err
+		}
+
+		problems := configStorj.Check()
+		for _, problem := range problems {
+			fmt.Println("  FAIL ", problem)
+		}
+		if len(problems) == 0 && offline {
+			return configStorj, nil
+		}
+
+		if len(problems) == 0 && method == "key" {
+			configStorj.AccessGrant, err = configStorj.RequestAccessGrant(ctx)
+			if err != nil {
+				fmt.Println("  FAIL ", err)
+			} else {
+				fmt.Println("  OK, derived the access grant from the API key")
+			}
+		}
+		if len(problems) == 0 && err == nil {
+			exists, err := storj.CheckConnection(ctx, configStorj.ForAccount(account), "")
+			switch {
+			case err != nil:
+				fmt.Println("  FAIL ", err)
+			case exists:
+				fmt.Println("  OK, the bucket is accessible")
+				return configStorj, nil
+			default:
+				fmt.Println("  OK, the bucket does not exist yet and is created by the first upload")
+				return configStorj, nil
+			}
+		}
+
+		retry, err := p.confirm("Enter the Storj settings again?", true)
+		if err != nil {
+			return configStorj, err
+		}
+		if !retry {
+			return configStorj, errors.New("no valid Storj configuration entered")
+		}
+	}
+}
+
+// writeConfigFile writes v as indented JSON to path, readable by the owner only,
+// since configuration files hold credentials.
+func writeConfigFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of an existing file.
+	return os.Chmod(path, 0600)
+}
+
+// writeProfile adds the profile to the configuration file at path, creating the file if needed,
+// and validates the result.
+func writeProfile(p *prompter, path, name string, profile interface{}) error {
+	file := make(map[string]json.RawMessage)
+	profiles := make(map[string]json.RawMessage)
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		if raw, ok := file["profiles"]; ok {
+			if err := json.Unmarshal(raw, &profiles); err != nil {
+				return fmt.Errorf("could not parse %s: %v", path, err)
+			}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if _, ok := profiles[name]; ok {
+		overwrite, err := p.confirm(fmt.Sprintf("Profile %s exists in %s, replace it?", name, path), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("profile %s was not replaced", name)
+		}
+	}
+
+	if profiles[name], err = json.Marshal(profile); err != nil {
+		return err
+	}
+	if file["profiles"], err = json.Marshal(profiles); err != nil {
+		return err
+	}
+	if err := writeConfigFile(path, file); err != nil {
+		return err
+	}
+	if _, err := config.Load(path); err != nil {
+		return err
+	}
+	fmt.Printf("Written profile %s to %s\n", name, path)
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/lock"
+	"utropicmedia/cpanel_storj_interface/logging"
+
+	"github.com/urfave/cli"
+)
+
+// lockDirName is the directory next to the configuration file that keeps the lock files by default.
+const lockDirName = "locks"
+
+// lockPollInterval is how often a run waiting for the lock of an account tries again.
+const lockPollInterval = 5 * time.Second
+
+// stageLock is the stage of a run that failed because another run held the lock of the account.
+const stageLock = "lock"
+
+// lockDirFlag selects where the lock files of the accounts are kept.
+var lockDirFlag = &cli.StringFlag{
+	Name:    "lock-dir",
+	Usage:   "keep the lock files, which stop two runs from backing up the same cPanel account at once, in `DIR` (default: locks in the directory of the configuration file)",
+	EnvVars: []string{"STORJ_CPANEL_LOCK_DIR"},
+}
+
+// lockDirectory returns the directory of the lock files selected by --lock-dir, or by default the
+// locks directory next to the configuration file, so that runs started from any working directory
+// with the same configuration exclude each other.
+func lockDirectory(cliContext *cli.Context, conf configuration) string {
+	if dir := cliContext.String("lock-dir"); dir != "" {
+		return dir
+	}
+	return filepath.Join(conf.dir, lockDirName)
+}
+
+// lockWaitFlag lets a run wait for another run of the same account.
+var lockWaitFlag = &cli.DurationFlag{
+	Name:  "lock-wait",
+	Usage: "wait this long for another run of the same cPanel account to finish (default: fail right away)",
+}
+
+// lockAccount locks the cPanel account of configcPanel for the run of command,
+// waiting up to wait for another run holding the lock, unless ctx is done first.
+func lockAccount(ctx context.Context, dir string, wait time.Duration, configcPanel cpanel.ConfigcPanel, command string) (*lock.Lock, error) {
+	path := lock.AccountPath(dir, configcPanel.HostName, configcPanel.UserName)
+	holder := lock.Holder{Job: jobID, Command: command}
+	deadline := time.Now().Add(wait)
+	for waiting := false; ; waiting = true {
+		accountLock, err := lock.Acquire(path, holder)
+		if err == nil {
+			if stale := accountLock.Stale; stale != nil {
+				logger.Warn("Took over the lock left behind by a run that ended without releasing it",
+					logging.F("file", path),
+					logging.F("pid", stale.PID),
+					logging.F("previousJob", stale.Job),
+					logging.F("since", stale.Started))
+			}
+			return accountLock, nil
+		}
+		remaining := time.Until(deadline)
+		if !lock.IsLocked(err) || remaining <= 0 {
+			return nil, err
+		}
+		if !waiting {
+			logger.Info("Waiting for another run of the account", logging.F("account", configcPanel.UserName), logging.F("error", err))
+		}
+		if remaining > lockPollInterval {
+			remaining = lockPollInterval
+		}
+		select {
+		case <-time.After(remaining):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
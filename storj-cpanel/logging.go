@@ -0,0 +1,133 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/hooks"
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// logger receives the progress messages of the commands.
+var logger = logging.Default()
+
+// jobID identifies the run in log entries and reports.
+var jobID = newJobID()
+
+// baseLogger is the logger configured by the log flags, before the job ID is added.
+var baseLogger = logging.Default()
+
+// logFile is the rotating log file selected with --log-file, closed when the app exits.
+var logFile *logging.RotatingFile
+
+// logLevel and logFormat are selected by the log flags.
+var (
+	logLevel  = logging.Info
+	logFormat = logging.Text
+)
+
+// logFlags configure logging for all commands.
+var logFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "log-level",
+		Value:   "info",
+		Usage:   "write log entries of `LEVEL` debug, info, warn or error and above; debug traces the cPanel API calls with credentials redacted",
+		EnvVars: []string{"STORJ_CPANEL_LOG_LEVEL"},
+	},
+	&cli.StringFlag{
+		Name:    "log-format",
+		Value:   "text",
+		Usage:   "write log entries as text or json",
+		EnvVars: []string{"STORJ_CPANEL_LOG_FORMAT"},
+	},
+	&cli.StringFlag{
+		Name:    "log-file",
+		Usage:   "write log entries to `FILE` instead of the standard output",
+		EnvVars: []string{"STORJ_CPANEL_LOG_FILE"},
+	},
+	&cli.IntFlag{
+		Name:  "log-max-size",
+		Usage: "rotate the log file when it grows beyond this many MB (default: never)",
+	},
+	&cli.IntFlag{
+		Name:  "log-max-files",
+		Value: logging.DefaultMaxBackups,
+		Usage: "number of rotated log files to keep",
+	},
+}
+
+// setupLogging creates the logger configured by the log flags and hands it to the cpanel and storj packages.
+// Every entry carries a job ID, so that the entries of one run can be told apart in a shared log file.
+func setupLogging(cliContext *cli.Context) error {
+	level, err := logging.ParseLevel(cliContext.String("log-level"))
+	if err != nil {
+		return err
+	}
+	// DEBUG_CPANEL_RESPONSES=1 was used to trace the cPanel API before --log-level existed.
+	if !cliContext.IsSet("log-level") && os.Getenv("DEBUG_CPANEL_RESPONSES") == "1" {
+		level = logging.Debug
+	}
+	format, err := logging.ParseFormat(cliContext.String("log-format"))
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if path := cliContext.String("log-file"); path != "" {
+		logFile, err = logging.OpenRotatingFile(path, int64(cliContext.Int("log-max-size"))*1024*1024, cliContext.Int("log-max-files"))
+		if err != nil {
+			return err
+		}
+		writer = logFile
+	}
+
+	logLevel, logFormat = level, format
+	baseLogger = logging.New(writer, level, format)
+	useJobID()
+	return nil
+}
+
+// output receives the messages of the commands meant for the user rather than the log.
+var output io.Writer = os.Stdout
+
+// logToStderr writes the log entries to the standard error instead of the standard output,
+// unless they go to a log file, for commands writing data to the standard output.
+func logToStderr() {
+	if logFile != nil {
+		return
+	}
+	baseLogger = logging.New(os.Stderr, logLevel, logFormat)
+	useJobID()
+}
+
+// startJob gives the next run a new job ID, for commands like daemon that run many jobs.
+func startJob() {
+	jobID = newJobID()
+	useJobID()
+}
+
+// useJobID adds the current job ID to the log entries of all packages.
+func useJobID() {
+	logger = baseLogger.With(logging.F("job", jobID))
+	cpanel.SetLogger(logger)
+	hooks.SetLogger(logger)
+	storj.SetLogger(logger)
+}
+
+// newJobID returns a random identifier of a run.
+func newJobID() string {
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id[:])
+}
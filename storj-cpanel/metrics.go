@@ -0,0 +1,145 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/metrics"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// pushJob is the job name under which metrics are pushed to the Pushgateway.
+const pushJob = "storj_cpanel"
+
+// Names of the metrics.
+const (
+	metricLastRun            = "storj_cpanel_last_run_timestamp_seconds"
+	metricLastRunSuccess     = "storj_cpanel_last_run_success"
+	metricLastSuccess        = "storj_cpanel_last_success_timestamp_seconds"
+	metricLastFailure        = "storj_cpanel_last_failure_timestamp_seconds"
+	metricGenerationDuration = "storj_cpanel_backup_generation_duration_seconds"
+	metricUploadBytes        = "storj_cpanel_upload_bytes"
+	metricUploadDuration     = "storj_cpanel_upload_duration_seconds"
+	metricBucketObjects      = "storj_cpanel_bucket_objects"
+	metricBucketBytes        = "storj_cpanel_bucket_bytes"
+	metricRuns               = "storj_cpanel_runs_total"
+	metricFailures           = "storj_cpanel_failures_total"
+	metricUploadedBytes      = "storj_cpanel_uploaded_bytes_total"
+)
+
+// pushMetricsFlag pushes the metrics of a run to a Pushgateway.
+var pushMetricsFlag = &cli.StringFlag{
+	Name:    "push-metrics",
+	Usage:   "push the metrics of the run to the Pushgateway at `URL` when it ends, e.g. http://localhost:9091",
+	EnvVars: []string{"STORJ_CPANEL_PUSHGATEWAY"},
+}
+
+// newMetricsRegistry returns a registry describing all metrics.
+func newMetricsRegistry() *metrics.Registry {
+	registry := metrics.NewRegistry()
+	registry.Describe(metricLastRun, metrics.Gauge, "Time the last run of store for the account ended.")
+	registry.Describe(metricLastRunSuccess, metrics.Gauge, "Whether the last run of store for the account succeeded (1) or failed (0).")
+	registry.Describe(metricLastSuccess, metrics.Gauge, "Time the last successful run of store for the account ended.")
+	registry.Describe(metricLastFailure, metrics.Gauge, "Time the last failed run of store for the account ended, by the stage that failed.")
+	registry.Describe(metricGenerationDuration, metrics.Gauge, "Time cPanel took to generate the last backup generated by a run.")
+	registry.Describe(metricUploadBytes, metrics.Gauge, "Size of the last backup uploaded.")
+	registry.Describe(metricUploadDuration, metrics.Gauge, "Time the upload of the last backup took.")
+	registry.Describe(metricBucketObjects, metrics.Gauge, "Number of objects in the bucket after the last run.")
+	registry.Describe(metricBucketBytes, metrics.Gauge, "Bytes stored in the bucket after the last run.")
+	registry.Describe(metricRuns, metrics.Counter, "Runs of store by result.")
+	registry.Describe(metricFailures, metrics.Counter, "Failed runs of store by the stage that failed: config, lock, hook, cpanel, storj or upload.")
+	registry.Describe(metricUploadedBytes, metrics.Counter, "Bytes of backups uploaded.")
+	return registry
+}
+
+// accountLabels identify the cPanel account of a run in the metrics.
+func accountLabels(host, account string) metrics.Labels {
+	return metrics.Labels{"host": host, "account": account}
+}
+
+// recordMetrics sets the gauges describing the finished run of report.
+func recordMetrics(registry *metrics.Registry, report *runReport) {
+	account := accountLabels(report.Host, report.Account)
+	finished := float64(report.Finished.Unix())
+	registry.Set(metricLastRun, account, finished)
+	switch report.Result {
+	case resultFailed:
+		registry.Set(metricLastRunSuccess, account, 0)
+		registry.Set(metricLastFailure, metrics.Labels{"host": report.Host, "account": report.Account, "stage": report.Stage}, finished)
+	case resultInterrupted:
+		// An interrupted run did not fail, but it did not back up the account either.
+		registry.Set(metricLastRunSuccess, account, 0)
+	default:
+		registry.Set(metricLastRunSuccess, account, 1)
+	}
+	// A run skipped because no backup was due is no new backup.
+	if report.Result == resultSuccess {
+		registry.Set(metricLastSuccess, account, finished)
+	}
+
+	for _, backup := range report.Backups {
+		if backup.Generated {
+			registry.Set(metricGenerationDuration, account, backup.GenerationDurationSeconds)
+		}
+		if backup.Result == resultSuccess {
+			registry.Set(metricUploadBytes, account, float64(backup.Bytes))
+			registry.Set(metricUploadDuration, account, backup.UploadDurationSeconds)
+		}
+	}
+
+	if report.BucketObjects != nil {
+		bucket := metrics.Labels{"host": report.Host, "account": report.Account, "bucket": report.Bucket}
+		registry.Set(metricBucketObjects, bucket, float64(*report.BucketObjects))
+		registry.Set(metricBucketBytes, bucket, float64(*report.BucketBytes))
+	}
+}
+
+// countMetrics adds the finished run of report to the counters, which only the daemon keeps across runs.
+func countMetrics(registry *metrics.Registry, report *runReport) {
+	account := accountLabels(report.Host, report.Account)
+	registry.Add(metricRuns, metrics.Labels{"host": report.Host, "account": report.Account, "result": report.Result}, 1)
+	if report.Result == resultFailed {
+		registry.Add(metricFailures, metrics.Labels{"host": report.Host, "account": report.Account, "stage": report.Stage}, 1)
+	}
+	for _, backup := range report.Backups {
+		if backup.Result == resultSuccess {
+			registry.Add(metricUploadedBytes, account, float64(backup.Bytes))
+		}
+	}
+}
+
+// pushMetrics pushes the gauges of the finished run of report to the Pushgateway selected by --push-metrics.
+// Failing to push is logged, but does not change the outcome of the run.
+func pushMetrics(cliContext *cli.Context, report *runReport) {
+	gatewayURL := cliContext.String("push-metrics")
+	if gatewayURL == "" {
+		return
+	}
+	registry := newMetricsRegistry()
+	recordMetrics(registry, report)
+	err := metrics.Push(context.Background(), gatewayURL, pushJob, accountLabels(report.Host, report.Account), registry)
+	if err != nil {
+		logger.Warn("Could not push metrics", logging.F("error", err))
+	}
+}
+
+// countBucket adds the number of objects and bytes in the bucket of session to report.
+func countBucket(ctx context.Context, session *storj.Session, report *runReport) {
+	objects, err := session.List(ctx, "")
+	if err != nil {
+		logger.Warn("Could not count the objects in the bucket", logging.F("error", err))
+		return
+	}
+	var bytes int64
+	for _, object := range objects {
+		bytes += object.Size
+	}
+	count := len(objects)
+	report.Bucket = session.Config.Bucket
+	report.BucketObjects, report.BucketBytes = &count, &bytes
+}
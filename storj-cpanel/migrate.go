@@ -0,0 +1,141 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// migrateConfiguration replaces the deprecated serializedScope by the converted accessGrant, in every
+// profile of the configuration file with --config or --profile, otherwise in the Storj configuration file.
+// The access grant is only written to the configuration file, which is readable by its owner only.
+func migrateConfiguration(cliContext *cli.Context) error {
+	if cliContext.IsSet("profile") || cliContext.IsSet("config") {
+		return migrateProfiles(cliContext.String("config"))
+	}
+	fullFileNameStorj := storjConfigFile
+	if cliContext.Args().Len() > 0 {
+		fullFileNameStorj = cliContext.Args().Get(0)
+	}
+	return migrateStorjFile(fullFileNameStorj)
+}
+
+// migrateStorjFile migrates the Storj configuration file at path.
+func migrateStorjFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	migrated, err := migrateStorjFields(fields)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if !migrated {
+		fmt.Println("Nothing to migrate in ", path)
+		return nil
+	}
+	if err := writeConfigFile(path, fields); err != nil {
+		return err
+	}
+	if _, err := config.LoadStorj(path); err != nil {
+		return err
+	}
+	fmt.Println("Replaced serializedScope by accessGrant in ", path)
+	return nil
+}
+
+// migrateProfiles migrates the Storj destination of every profile of the configuration file at path.
+func migrateProfiles(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := make(map[string]json.RawMessage)
+	profiles := make(map[string]map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	if err := json.Unmarshal(file["profiles"], &profiles); err != nil {
+		return fmt.Errorf("could not parse %s: %v", path, err)
+	}
+
+	var names []string
+	for name, profile := range profiles {
+		fields := make(map[string]json.RawMessage)
+		if raw, ok := profile["storj"]; ok {
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return fmt.Errorf("could not parse %s: %v", path, err)
+			}
+		}
+		migrated, err := migrateStorjFields(fields)
+		if err != nil {
+			return fmt.Errorf("%s: profile %s: %v", path, name, err)
+		}
+		if !migrated {
+			continue
+		}
+		if profile["storj"], err = json.Marshal(fields); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	if names == nil {
+		fmt.Println("Nothing to migrate in ", path)
+		return nil
+	}
+
+	if file["profiles"], err = json.Marshal(profiles); err != nil {
+		return err
+	}
+	if err := writeConfigFile(path, file); err != nil {
+		return err
+	}
+	if _, err := config.Load(path); err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Printf("Replaced serializedScope by accessGrant in profile %s of %s\n", name, path)
+	}
+	return nil
+}
+
+// migrateStorjFields replaces serializedScope by the converted accessGrant in the fields
+// of a Storj configuration. It reports whether there was a serialized scope to convert.
+func migrateStorjFields(fields map[string]json.RawMessage) (bool, error) {
+	raw, ok := fields["serializedScope"]
+	if !ok {
+		return false, nil
+	}
+	var scope string
+	if err := json.Unmarshal(raw, &scope); err != nil {
+		return false, fmt.Errorf("serializedScope is not a string: %v", err)
+	}
+	if scope == "" {
+		delete(fields, "serializedScope")
+		return true, nil
+	}
+	if _, ok := fields["accessGrant"]; ok {
+		return false, fmt.Errorf("both accessGrant and serializedScope are set, remove serializedScope")
+	}
+	accessGrant, err := storj.ConvertSerializedScope(scope)
+	if err != nil {
+		return false, err
+	}
+	if fields["accessGrant"], err = json.Marshal(accessGrant); err != nil {
+		return false, err
+	}
+	delete(fields, "serializedScope")
+	return true, nil
+}
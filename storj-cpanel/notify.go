@@ -0,0 +1,103 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/notify"
+
+	"github.com/urfave/cli"
+)
+
+// notificationsFile configures the notifications about runs, unless --notify names another file.
+const notificationsFile = "./config/notifications.json"
+
+// notifyFlag selects the notification configuration file.
+var notifyFlag = &cli.StringFlag{
+	Name:  "notify",
+	Value: notificationsFile,
+	Usage: "send the notifications configured in `FILE` when the run ends; nothing is sent when the default file does not exist",
+}
+
+// loadNotifications reads the notification configuration selected by --notify.
+// It returns nil when the default file does not exist.
+func loadNotifications(cliContext *cli.Context) (*notify.Config, error) {
+	path := cliContext.String("notify")
+	configNotify, err := config.LoadNotifications(path)
+	if os.IsNotExist(err) && !cliContext.IsSet("notify") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &configNotify, nil
+}
+
+// notifyRun sends the notifications about the finished run of report.
+// Failing to notify is logged, but does not change the outcome of the run.
+func notifyRun(configNotify *notify.Config, report *runReport) {
+	if configNotify == nil {
+		return
+	}
+	event := notify.Event{
+		Job:      report.Job,
+		Command:  report.Command,
+		Host:     report.Host,
+		Account:  report.Account,
+		Started:  report.Started,
+		Finished: report.Finished,
+		Result:   report.Result,
+		Error:    report.Error,
+		Summary:  report.summary(),
+		Report:   report,
+	}
+	if err := notify.Notify(context.Background(), *configNotify, event); err != nil {
+		logger.Warn("Could not send notifications", logging.F("error", err))
+	}
+}
+
+// summary describes in one line what the run did with backups.
+func (report *runReport) summary() string {
+	var uploaded, skipped, deleted, verified, unverified, failed int
+	var bytes int64
+	for _, backup := range report.Backups {
+		switch backup.Result {
+		case resultSuccess:
+			uploaded++
+			bytes += backup.Bytes
+		case resultSkipped:
+			skipped++
+		case resultDeleted:
+			deleted++
+		case resultVerified:
+			verified++
+		case resultUnverified:
+			unverified++
+		case resultFailed, resultInterrupted:
+			failed++
+		}
+	}
+
+	switch {
+	case report.Command == "prune":
+		return fmt.Sprintf("%d backup(s) deleted", deleted)
+	case report.Command == "verify":
+		return fmt.Sprintf("%d backup(s) verified, %d without a stored hash, %d failed", verified, unverified, failed)
+	case len(report.Backups) == 0:
+		return "no backups uploaded"
+	}
+	summary := fmt.Sprintf("%d backup(s) uploaded (%.1f MB)", uploaded, float64(bytes)/1024/1024)
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	return summary
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"utropicmedia/cpanel_storj_interface/config"
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/ratelimit"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+const configFile = "./config/storj-cpanel.json"
+
+// configFlag selects the configuration file with profiles.
+var configFlag = &cli.StringFlag{
+	Name:  "config",
+	Value: configFile,
+	Usage: "configuration file with profiles, used instead of separate cPanel and Storj configuration files",
+}
+
+// profileFlag selects a profile of the configuration file.
+var profileFlag = &cli.StringFlag{
+	Name:  "profile",
+	Usage: "use the cPanel source and Storj destination of profile `NAME` in the configuration file",
+}
+
+// configuration is the cPanel source and Storj destination a command works with.
+type configuration struct {
+	// profile is set when the configuration was read from a profile of the configuration file.
+	profile *config.Profile
+	cpanel  cpanel.ConfigcPanel
+	storj   storj.ConfigStorj
+	// args are the positional arguments following the configuration file names.
+	args []string
+	// dir is the directory of the configuration file, or of the cPanel configuration file.
+	dir string
+}
+
+// loadConfiguration reads the configuration of a command.
+// With --profile or --config it is read from a profile of the configuration file,
+// otherwise from the separate configuration files named by the leading positional arguments,
+// which are listed in files as "cpanel" or "storj".
+func loadConfiguration(cliContext *cli.Context, files ...string) (configuration, error) {
+	args := cliContext.Args().Slice()
+
+	if cliContext.IsSet("profile") || cliContext.IsSet("config") {
+		file, err := config.Load(cliContext.String("config"))
+		if err != nil {
+			return configuration{}, err
+		}
+		profile, err := file.Profile(cliContext.String("profile"))
+		if err != nil {
+			return configuration{}, err
+		}
+		fmt.Fprintf(output, "Using profile %s of %s\n", profile.Name, cliContext.String("config"))
+		return configuration{
+			profile: &profile,
+			cpanel:  profile.CPanel,
+			storj:   profile.Storj,
+			args:    args,
+			dir:     filepath.Dir(cliContext.String("config")),
+		}, nil
+	}
+
+	var conf configuration
+	for _, file := range files {
+		var err error
+		switch file {
+		case "cpanel":
+			fullFileName := cpanelConfigFile
+			if len(args) > 0 {
+				fullFileName, args = args[0], args[1:]
+			}
+			conf.cpanel, err = config.LoadCPanel(fullFileName)
+			conf.dir = filepath.Dir(fullFileName)
+		case "storj":
+			fullFileName := storjConfigFile
+			if len(args) > 0 {
+				fullFileName, args = args[0], args[1:]
+			}
+			conf.storj, err = config.LoadStorj(fullFileName)
+			if conf.dir == "" {
+				conf.dir = filepath.Dir(fullFileName)
+			}
+		}
+		if err != nil {
+			return configuration{}, err
+		}
+	}
+	conf.args = args
+	return conf, nil
+}
+
+// arg returns the i-th positional argument following the configuration file names, or "".
+func (conf configuration) arg(i int) string {
+	if i < len(conf.args) {
+		return conf.args[i]
+	}
+	return ""
+}
+
+// limitBandwidth replaces the configured bandwidth limits with bandwidth, unless it is nil.
+func (conf *configuration) limitBandwidth(bandwidth *ratelimit.Config) {
+	if bandwidth == nil {
+		return
+	}
+	conf.cpanel.Bandwidth = *bandwidth
+	conf.storj.Bandwidth = *bandwidth
+}
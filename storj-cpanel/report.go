@@ -0,0 +1,304 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/state"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// Results of a run and of the backups in its report.
+const (
+	resultSuccess = "success"
+	resultFailed  = "failed"
+	resultSkipped = "skipped"
+	resultDeleted = "deleted"
+	// resultInterrupted is the result of a run stopped by SIGINT or SIGTERM.
+	resultInterrupted = "interrupted"
+	// resultPlanned is the result of a backup a dry run would upload or delete.
+	resultPlanned = "planned"
+	// resultVerified and resultUnverified are the results of backups checked by verify;
+	// a backup without a stored hash cannot be verified.
+	resultVerified   = "verified"
+	resultUnverified = "unverified"
+)
+
+// Stages of a run of store, reported as the stage that failed; hooks failing a run report stageHook.
+const (
+	stageConfig = "config"
+	stageCPanel = "cpanel"
+	stageStorj  = "storj"
+	stageUpload = "upload"
+	stageVerify = "verify"
+)
+
+// Types of the scopes reported.
+const (
+	scopeNone       = "none"
+	scopeFull       = "access-grant"
+	scopeRestricted = "restricted-access-grant"
+)
+
+// reportFlag prints the machine-readable report of a run.
+var reportFlag = &cli.StringFlag{
+	Name:  "report",
+	Usage: "print a report of the run in `FORMAT` json to the standard output when it ends",
+}
+
+// reportFileFlag writes the machine-readable report of a run to a file.
+var reportFileFlag = &cli.StringFlag{
+	Name:  "report-file",
+	Usage: "write the JSON report of the run to `FILE`",
+}
+
+// runReport is the machine-readable report of a run of store, prune or verify.
+type runReport struct {
+	Job      string    `json:"job"`
+	Command  string    `json:"command"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Host and Account identify the configured cPanel account.
+	Host    string `json:"host,omitempty"`
+	Account string `json:"account,omitempty"`
+	// Result is success, failed, interrupted, or skipped when no backup was due.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Stage is the stage of a failed run that failed: config, lock, hook, cpanel, storj, upload or verify.
+	Stage string `json:"stage,omitempty"`
+	// DryRun is set for a run with --dry-run, whose backups are planned rather than uploaded or deleted.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	Backups []backupReport `json:"backups"`
+	// BucketObjects and BucketBytes are the contents of Bucket after the run,
+	// only counted for the metrics.
+	Bucket        string `json:"bucket,omitempty"`
+	BucketObjects *int   `json:"bucketObjects,omitempty"`
+	BucketBytes   *int64 `json:"bucketBytes,omitempty"`
+
+	// ScopeType is the type of access grant handed out by the run, or "none".
+	ScopeType string       `json:"scopeType"`
+	Scope     *scopeReport `json:"scope,omitempty"`
+}
+
+// backupReport describes a backup that was uploaded, skipped, deleted or planned by a run.
+type backupReport struct {
+	Host     string `json:"host"`
+	Account  string `json:"account"`
+	FileName string `json:"fileName"`
+	// BackupTime is when cPanel generated the backup.
+	BackupTime time.Time `json:"backupTime"`
+	// Generated reports whether the backup was generated by the run.
+	Generated                 bool    `json:"generated"`
+	GenerationDurationSeconds float64 `json:"generationDurationSeconds,omitempty"`
+
+	Bucket                string  `json:"bucket,omitempty"`
+	ObjectKey             string  `json:"objectKey,omitempty"`
+	Bytes                 int64   `json:"bytes"`
+	SHA256                string  `json:"sha256,omitempty"`
+	UploadDurationSeconds float64 `json:"uploadDurationSeconds,omitempty"`
+
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// scopeReport describes the access grant handed out by a run, without the grant itself.
+type scopeReport struct {
+	Fingerprint string `json:"fingerprint"`
+	Satellite   string `json:"satellite"`
+	Bucket      string `json:"bucket"`
+	Prefix      string `json:"prefix,omitempty"`
+	Permissions string `json:"permissions"`
+}
+
+// newRunReport starts the report of a run of command.
+func newRunReport(command string) *runReport {
+	return &runReport{
+		Job:       jobID,
+		Command:   command,
+		Started:   time.Now(),
+		Stage:     stageConfig,
+		Backups:   []backupReport{},
+		ScopeType: scopeNone,
+	}
+}
+
+// uploaded adds the outcome of the upload of a backup.
+func (report *runReport) uploaded(cpanelReader *cpanel.Cpaneldata, upload state.Upload, duration time.Duration) {
+	backup := backupFromReader(cpanelReader)
+	backup.Bucket = upload.Bucket
+	backup.ObjectKey = upload.ObjectKey
+	backup.Bytes = upload.Size
+	backup.SHA256 = upload.SHA256
+	backup.UploadDurationSeconds = duration.Seconds()
+	backup.Result = upload.Result
+	backup.Error = upload.Error
+	report.Backups = append(report.Backups, backup)
+}
+
+// skipped adds a backup that was not uploaded because it was uploaded before.
+func (report *runReport) skipped(cpanelReader *cpanel.Cpaneldata, upload state.Upload) {
+	backup := backupFromReader(cpanelReader)
+	backup.Bucket = upload.Bucket
+	backup.ObjectKey = upload.ObjectKey
+	backup.SHA256 = upload.SHA256
+	backup.Result = resultSkipped
+	report.Backups = append(report.Backups, backup)
+}
+
+// skippedBackup adds a backup in the home directory that was found in the bucket already.
+func (report *runReport) skippedBackup(configcPanel cpanel.ConfigcPanel, backup cpanel.FullBackup, bucket, key string) {
+	report.Backups = append(report.Backups, backupReport{
+		Host:       configcPanel.HostName,
+		Account:    configcPanel.UserName,
+		FileName:   backup.File,
+		BackupTime: backup.Time,
+		Bucket:     bucket,
+		ObjectKey:  key,
+		Result:     resultSkipped,
+	})
+}
+
+// planned adds a backup a dry run of store would upload, generating it first when the plan says so.
+func (report *runReport) planned(configcPanel cpanel.ConfigcPanel, plan cpanel.BackupPlan, bucket, key string) {
+	report.Backups = append(report.Backups, backupReport{
+		Host:       configcPanel.HostName,
+		Account:    configcPanel.UserName,
+		FileName:   plan.Backup.File,
+		BackupTime: plan.Backup.Time,
+		Generated:  plan.Generate,
+		Bucket:     bucket,
+		ObjectKey:  key,
+		Bytes:      plan.Size,
+		Result:     resultPlanned,
+	})
+}
+
+// deleted adds a backup deleted from the bucket by prune, or one a dry run would delete.
+func (report *runReport) deleted(configcPanel cpanel.ConfigcPanel, configStorj storj.ConfigStorj, object storj.Object) {
+	fields, _ := configStorj.ParseObjectKey(object.Key)
+	result := resultDeleted
+	if report.DryRun {
+		result = resultPlanned
+	}
+	report.Backups = append(report.Backups, backupReport{
+		Host:       configcPanel.HostName,
+		Account:    configcPanel.UserName,
+		FileName:   fields.FileName,
+		BackupTime: fields.Time,
+		Bucket:     configStorj.Bucket,
+		ObjectKey:  object.Key,
+		Bytes:      object.Size,
+		Result:     result,
+	})
+}
+
+func backupFromReader(cpanelReader *cpanel.Cpaneldata) backupReport {
+	return backupReport{
+		Host:                      cpanelReader.Host,
+		Account:                   cpanelReader.Account,
+		FileName:                  cpanelReader.FileName,
+		BackupTime:                cpanelReader.Time,
+		Generated:                 cpanelReader.Generated,
+		GenerationDurationSeconds: cpanelReader.GenerationDuration.Seconds(),
+		Bytes:                     cpanelReader.Size,
+	}
+}
+
+// issued records the access grant handed out by the run.
+func (report *runReport) issued(scope string, info storj.ScopeInfo, restrict string) {
+	if scope == "" {
+		return
+	}
+	report.ScopeType = scopeFull
+	if restrict == "restrict" {
+		report.ScopeType = scopeRestricted
+	}
+	report.Scope = &scopeReport{
+		Fingerprint: storj.Fingerprint(scope),
+		Satellite:   info.Satellite,
+		Bucket:      info.Bucket,
+		Prefix:      info.Prefix,
+		Permissions: permissions(state.IssuedScope{
+			Restricted:      info.Restricted,
+			DisallowReads:   info.DisallowReads,
+			DisallowWrites:  info.DisallowWrites,
+			DisallowLists:   info.DisallowLists,
+			DisallowDeletes: info.DisallowDeletes,
+		}),
+	}
+}
+
+// checkReportFlags verifies the report format before the run starts. A JSON report is all the
+// standard output carries, so that it can be parsed; log entries and messages go to the standard error.
+func checkReportFlags(cliContext *cli.Context) error {
+	format := cliContext.String("report")
+	if format != "" && format != "json" {
+		return fmt.Errorf("invalid --report %q, expected json", format)
+	}
+	if format == "json" {
+		logToStderr()
+		output = os.Stderr
+	}
+	return nil
+}
+
+// finish completes the report with the outcome err of the run and writes it
+// as selected by --report and --report-file. It returns err, or the error writing the report.
+func (report *runReport) finish(cliContext *cli.Context, err error) error {
+	report.Finished = time.Now()
+	switch {
+	case err != nil && cliContext.Context.Err() != nil:
+		report.Result = resultInterrupted
+		report.Error = err.Error()
+	case err != nil:
+		report.Result = resultFailed
+		report.Error = err.Error()
+	case report.Result == "":
+		report.Result = resultSuccess
+	}
+	if err == nil {
+		report.Stage = ""
+	}
+
+	format := cliContext.String("report")
+	file := cliContext.String("report-file")
+	if format == "" && file == "" {
+		return err
+	}
+
+	data, marshalErr := json.MarshalIndent(report, "", "  ")
+	if marshalErr != nil {
+		return firstError(err, marshalErr)
+	}
+	data = append(data, '\n')
+
+	if format == "json" {
+		if _, writeErr := os.Stdout.Write(data); writeErr != nil {
+			return firstError(err, writeErr)
+		}
+	}
+	if file != "" {
+		if writeErr := ioutil.WriteFile(file, data, 0600); writeErr != nil {
+			return firstError(err, fmt.Errorf("could not write report: %v", writeErr))
+		}
+	}
+	return err
+}
+
+// firstError returns err, or other when err is nil.
+func firstError(err, other error) error {
+	if err != nil {
+		return err
+	}
+	return other
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// exitInterruptedAgain is the exit status when a second signal ends the process right away.
+const exitInterruptedAgain = 130
+
+// handleSignals returns a context that is canceled on the first SIGINT or SIGTERM, so that a run
+// stops uploading, aborts the unfinished object, and releases its lock and backup file before it exits.
+// A second signal exits right away.
+func handleSignals() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		logger.Warn("Interrupted, stopping the run (signal again to exit right away)", logging.F("signal", received.String()))
+		cancel()
+
+		received = <-signals
+		logger.Error("Interrupted again, exiting without cleaning up", logging.F("signal", received.String()))
+		if logFile != nil {
+			logFile.Close()
+		}
+		os.Exit(exitInterruptedAgain)
+	}()
+	return ctx
+}
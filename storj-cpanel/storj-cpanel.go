@@ -5,18 +5,51 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"utropicmedia/cpanel_storj_interface/config"
 	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/ratelimit"
+	"utropicmedia/cpanel_storj_interface/state"
 	"utropicmedia/cpanel_storj_interface/storj"
 
+	"github.com/skip2/go-qrcode"
 	"github.com/urfave/cli"
 )
 
 const cpanelConfigFile = "./config/cpanel_property.json"
 const storjConfigFile = "./config/storj_config.json"
+const stateFile = "./config/upload_state.json"
+
+const scopeLogFile = "./config/issued_scopes.json"
+
+// scopeLogFlag selects the audit log of issued scopes.
+var scopeLogFlag = &cli.StringFlag{
+	Name:  "scope-log",
+	Value: scopeLogFile,
+	Usage: "file recording the serialized scopes handed out",
+}
+
+// stateFlag selects the upload history used to skip backups that were already uploaded.
+var stateFlag = &cli.StringFlag{
+	Name:  "state",
+	Value: stateFile,
+	Usage: "file recording which backups were uploaded",
+}
+
+// bwlimitFlag overrides the bandwidth limits of the configuration files.
+var bwlimitFlag = &cli.StringFlag{
+	Name:  "bwlimit",
+	Usage: "limit uploads and cPanel downloads to `RATE` bytes per second (e.g. 512K, 10M), replacing the configured limit and schedule; 0 disables limiting",
+}
 
 // Create command-line tool to read from CLI.
 var app = cli.NewApp()
@@ -27,6 +60,8 @@ func setAppInfo() {
 	app.Usage = "Backup your cPanel file to the decentralized Storj network"
 	app.Authors = []*cli.Author{{Name: "Satyam Shivam - Utropicmedia", Email: "development@utropicmedia.com"}}
 	app.Version = "1.0.0"
+	app.Flags = logFlags
+	app.Before = setupLogging
 
 }
 
@@ -72,10 +107,14 @@ func setCommands() {
 
 				// Create a buffer as an io.Reader implementor.
 				buf := bytes.NewBuffer(data)
-				_, err := storj.ConnectStorjReadUploadData(fullFileName, buf, fileName, keyValue, restrict)
+				configStorj, err := config.LoadStorj(fullFileName)
+				if err != nil {
+					return err
+				}
+				_, err = storj.ConnectStorjReadUploadDataWithConfig(configStorj, buf, fileName, keyValue, restrict)
 
 				if err != nil {
-					fmt.Println("Error while uploading data to the Storj bucket")
+					logger.Error("Error while uploading data to the Storj bucket", logging.F("error", err))
 				}
 				return err
 			},
@@ -84,80 +123,963 @@ func setCommands() {
 			Name:    "store",
 			Aliases: []string{"s"},
 			Usage:   "Command to connect and transfer a back-up file from a desired cPanel instance to given Storj Bucket",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "reuse",
+					Usage: "upload the newest complete backup in the home directory instead of generating a new one",
+				},
+				&cli.DurationFlag{
+					Name:  "max-age",
+					Usage: "with --reuse, only reuse a backup younger than this (e.g. 24h), otherwise generate a new one",
+				},
+				&cli.StringFlag{
+					Name:  "backup-file",
+					Usage: "upload the named existing backup file from the home directory",
+				},
+				&cli.BoolFlag{
+					Name:  "all-pending",
+					Usage: "upload every complete backup in the home directory that is not in the bucket yet",
+				},
+				&cli.BoolFlag{
+					Name:  "force",
+					Usage: "upload the backup even if the upload history shows it was uploaded before",
+				},
+				&cli.BoolFlag{
+					Name:  "if-due",
+					Usage: "only upload when the schedule of the profile says a backup is due, based on the upload history",
+				},
+				configFlag,
+				profileFlag,
+				stateFlag,
+				scopeLogFlag,
+				bwlimitFlag,
+				reportFlag,
+				reportFileFlag,
+				notifyFlag,
+				pushMetricsFlag,
+				lockDirFlag,
+				lockWaitFlag,
+				dryRunFlag,
+			},
 			//\n    arguments-\n      1. fileName [optional] = provide full file name (with complete path), storing cPanel properties in JSON format\n   if this fileName is not given, then data is read from ./config/cpanel_property.json\n      2. fileName [optional] = provide file name, storing Storj configuration in JSON format\n     if this fileName is not given, then data is read from ./config/storj_config.json\n   example = ./storj_cpanel c ./config/cpanel_property.json ./config/storj_config.json\n",
+			Action: func(cliContext *cli.Context) (err error) {
+				if err := checkReportFlags(cliContext); err != nil {
+					return err
+				}
+				notifications, err := loadNotifications(cliContext)
+				if err != nil {
+					return err
+				}
+				report := newRunReport("store")
+				report.DryRun = cliContext.Bool("dry-run")
+				defer func() {
+					err = report.finish(cliContext, err)
+					// A dry run changes nothing worth notifying about or recording in the metrics.
+					if !report.DryRun {
+						notifyRun(notifications, report)
+						pushMetrics(cliContext, report)
+					}
+				}()
+
+				conf, err := loadConfiguration(cliContext, "cpanel", "storj")
+				if err != nil {
+					return err
+				}
+				report.Host, report.Account = conf.cpanel.HostName, conf.cpanel.UserName
+
+				bandwidth, err := bandwidthOverride(cliContext)
+				if err != nil {
+					return err
+				}
+				conf.limitBandwidth(bandwidth)
+
+				if cliContext.Bool("all-pending") && (cliContext.Bool("reuse") || cliContext.IsSet("backup-file")) {
+					return errors.New("--all-pending cannot be combined with --reuse or --backup-file")
+				}
+				return runStore(cliContext.Context, conf, storeOptions{
+					keyValue: conf.arg(0),
+					restrict: conf.arg(1),
+					backup: cpanel.BackupOptions{
+						Reuse:    cliContext.Bool("reuse"),
+						MaxAge:   cliContext.Duration("max-age"),
+						FileName: cliContext.String("backup-file"),
+					},
+					allPending:  cliContext.Bool("all-pending"),
+					force:       cliContext.Bool("force"),
+					ifDue:       cliContext.Bool("if-due"),
+					state:       cliContext.String("state"),
+					scopeLog:    cliContext.String("scope-log"),
+					lockDir:     lockDirectory(cliContext, conf),
+					lockWait:    cliContext.Duration("lock-wait"),
+					countBucket: cliContext.String("push-metrics") != "",
+					dryRun:      report.DryRun,
+				}, report)
+			},
+		},
+		{
+			Name:    "list",
+			Aliases: []string{"l"},
+			Usage:   "Command to list the backups of the cPanel account stored in the Storj bucket",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "all",
+					Usage: "list the backups of all accounts below the upload path, not only those of the configured cPanel account",
+				},
+				configFlag,
+				profileFlag,
+			},
+			//\n arguments- 1. fileName [optional] = cPanel configuration file, 2. fileName [optional] = Storj configuration file, 3. key [optional] = use the API key instead of the access grant
 			Action: func(cliContext *cli.Context) error {
+				conf, err := loadConfiguration(cliContext, "cpanel", "storj")
+				if err != nil {
+					return err
+				}
+				configcPanel, configStorj, keyValue := conf.cpanel, conf.storj, conf.arg(0)
 
-				// Default configuration file names.
-				var fullFileNameStorj = storjConfigFile
-				var fullFileNamecPanel = cpanelConfigFile
-				var keyValue string
-				var restrict string
+				prefix := configStorj.AccountPrefix(configcPanel.HostName, configcPanel.UserName)
+				if cliContext.Bool("all") {
+					if configStorj.BucketPerAccount {
+						return errors.New("--all cannot be used with bucketPerAccount, each account has its own bucket")
+					}
+					prefix = configStorj.AccountPrefix("", "")
+				}
+				configStorj = configStorj.ForAccount(configcPanel.UserName)
 
-				// process arguments - Reading fileName from the command line.
-				var foundFirstFileName = false
-				var foundSecondFileName = false
-				var foundThirdFileName = false
-				if len(cliContext.Args().Slice()) > 0 {
-					for i := 0; i < len(cliContext.Args().Slice()); i++ {
+				ctx := context.Background()
+				session, err := storj.OpenSession(ctx, configStorj, keyValue, "")
+				if err != nil {
+					return err
+				}
+				defer session.Close()
 
-						if !foundFirstFileName {
-							fullFileNamecPanel = cliContext.Args().Slice()[i]
-							foundFirstFileName = true
-						} else {
-							if !foundSecondFileName {
-								fullFileNameStorj = cliContext.Args().Slice()[i]
-								foundSecondFileName = true
-							} else {
-								if !foundThirdFileName {
-									keyValue = cliContext.Args().Slice()[i]
-									foundThirdFileName = true
-								} else {
-									restrict = cliContext.Args().Slice()[i]
-								}
-							}
-						}
+				objects, err := session.List(ctx, prefix)
+				if err != nil {
+					return err
+				}
+
+				fmt.Println(" ")
+				writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(writer, "ACCOUNT\tBACKUP DATE\tFILE\tSIZE\tUPLOADED\tOBJECT KEY")
+				all := cliContext.Bool("all")
+				for _, object := range objects {
+					fields, ok := configStorj.ParseObjectKey(object.Key)
+					if !all {
+						// Without {account} in the key template the backups of all accounts share the prefix.
+						fields, ok = configStorj.AccountBackup(object.Key, configcPanel.HostName, configcPanel.UserName)
+					}
+					if !ok {
+						continue
+					}
+					if fields.Account == "" && !all {
+						fields.Account = configcPanel.UserName
 					}
+					backupDate := "-"
+					if !fields.Time.IsZero() {
+						backupDate = fields.Time.Format("2006-01-02")
+					}
+					fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\t%s\n",
+						fields.Account, backupDate, fields.FileName, object.Size, object.Created.Format(time.RFC3339), object.Key)
+				}
+				return writer.Flush()
+			},
+		},
+		{
+			Name:  "get",
+			Usage: "Command to download a backup from the Storj bucket to a file or the standard output, verifying its hash",
+			Flags: getFlags,
+			//\n arguments- 1. fileName [optional] = cPanel configuration file, 2. fileName [optional] = Storj configuration file, 3. key [optional] = use the API key instead of the access grant
+			Action: runGet,
+		},
+		{
+			Name:  "verify",
+			Usage: "Command to download the latest or every backup of the cPanel account without storing it and check its hash",
+			Flags: verifyFlags,
+			//\n arguments- 1. fileName [optional] = cPanel configuration file, 2. fileName [optional] = Storj configuration file, 3. key [optional] = use the API key instead of the access grant
+			Action: runVerify,
+		},
+		{
+			Name:  "prune",
+			Usage: "Command to delete the backups of the cPanel account that the retention rules no longer keep",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "keep-last",
+					Usage: "keep this number of newest backups, replacing the retention of the profile",
+				},
+				&cli.IntFlag{
+					Name:  "keep-days",
+					Usage: "keep the backups uploaded within this number of days, replacing the retention of the profile",
+				},
+				configFlag,
+				profileFlag,
+				reportFlag,
+				reportFileFlag,
+				notifyFlag,
+				dryRunFlag,
+			},
+			//\n arguments- 1. fileName [optional] = cPanel configuration file, 2. fileName [optional] = Storj configuration file, 3. key [optional] = use the API key instead of the access grant
+			Action: func(cliContext *cli.Context) (err error) {
+				if err := checkReportFlags(cliContext); err != nil {
+					return err
+				}
+				notifications, err := loadNotifications(cliContext)
+				if err != nil {
+					return err
+				}
+				report := newRunReport("prune")
+				report.DryRun = cliContext.Bool("dry-run")
+				defer func() {
+					err = report.finish(cliContext, err)
+					if !report.DryRun {
+						notifyRun(notifications, report)
+					}
+				}()
+
+				conf, err := loadConfiguration(cliContext, "cpanel", "storj")
+				if err != nil {
+					return err
+				}
+				keyValue := conf.arg(0)
+				report.Host, report.Account = conf.cpanel.HostName, conf.cpanel.UserName
 
+				var retention config.Retention
+				if conf.profile != nil {
+					retention = conf.profile.Retention
+				}
+				if cliContext.IsSet("keep-last") || cliContext.IsSet("keep-days") {
+					retention = config.Retention{
+						KeepLast: cliContext.Int("keep-last"),
+						KeepDays: cliContext.Int("keep-days"),
+					}
+				}
+				if retention.KeepLast < 0 || retention.KeepDays < 0 {
+					return errors.New("--keep-last and --keep-days must not be negative")
+				}
+				if retention.IsZero() {
+					return errors.New("no retention configured, set retention in the profile or use --keep-last or --keep-days")
 				}
 
-				// Establish connection with cPanel and get io.Reader implementor.
-				cpanelReader, err := cpanel.ConnectToCpanel(fullFileNamecPanel)
+				report.Stage = stageStorj
+				configStorj := conf.storj.ForAccount(conf.cpanel.UserName)
+				ctx := cliContext.Context
+				openSession := storj.OpenSession
+				if report.DryRun {
+					openSession = storj.OpenSessionReadOnly
+				}
+				session, err := openSession(ctx, configStorj, keyValue, "")
 				if err != nil {
-					fmt.Println("Failed to establish connection with cPanel:\n")
 					return err
 				}
+				defer session.Close()
 
-				// Fetch fullbackup from cPanel instance
-				// and simultaneously store them into desired Storj bucket.
-				scope, err := storj.ConnectStorjReadUploadData(fullFileNameStorj, cpanelReader.FileHandle, cpanelReader.FileName, keyValue, restrict)
+				objects, err := session.List(ctx, configStorj.AccountPrefix(conf.cpanel.HostName, conf.cpanel.UserName))
 				if err != nil {
-					fmt.Println("Error while fetching cPanel backup data and uploading them to bucket:")
 					return err
 				}
+				// Only the backups of the account are subject to its retention. Without {account} in
+				// the key template the prefix is shared with the backups of other accounts.
+				var backups []storj.Object
+				for _, object := range objects {
+					if _, ok := configStorj.AccountBackup(object.Key, conf.cpanel.HostName, conf.cpanel.UserName); ok {
+						backups = append(backups, object)
+					}
+				}
+
+				expired := retention.Expired(backups, time.Now())
+				if report.DryRun {
+					for _, object := range expired {
+						report.deleted(conf.cpanel, configStorj, object)
+					}
+					return printPrunePlan(backups, expired)
+				}
+				for _, object := range expired {
+					if err := session.Delete(ctx, object); err != nil {
+						logger.Error("Error while deleting backup", logging.F("key", object.Key), logging.F("error", err))
+						return err
+					}
+					logger.Info("Deleted backup", logging.F("key", object.Key), logging.F("uploaded", object.Created))
+					report.deleted(conf.cpanel, configStorj, object)
+				}
+				logger.Info("Pruned backups",
+					logging.F("account", conf.cpanel.UserName),
+					logging.F("deleted", len(expired)),
+					logging.F("backups", len(backups)))
+				return nil
+			},
+		},
+		{
+			Name:  "daemon",
+			Usage: "Keep running, back up every profile with a schedule whenever a backup is due and serve metrics",
+			Flags: daemonFlags,
+			//\n arguments- 1. key [optional] = use the API key instead of the access grant, 2. restrict [optional] = print a restricted serialized scope
+			Action: runDaemon,
+		},
+		{
+			Name:  "share",
+			Usage: "Command to create a read-only or list-only serialized scope for a backup object or prefix, without uploading anything",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: "object key or key prefix in the bucket to share (default: the configured upload path)",
+				},
+				&cli.StringFlag{
+					Name:  "account",
+					Usage: "cPanel account whose bucket is shared, required with bucketPerAccount",
+				},
+				&cli.BoolFlag{
+					Name:  "list-only",
+					Usage: "only allow listing objects, not downloading them",
+				},
+				&cli.StringFlag{
+					Name:  "not-before",
+					Usage: "time the scope becomes valid, in RFC 3339 format (e.g. 2020-03-01T00:00:00Z)",
+				},
+				&cli.StringFlag{
+					Name:  "not-after",
+					Usage: "time the scope expires, in RFC 3339 format",
+				},
+				&cli.DurationFlag{
+					Name:  "expires-in",
+					Usage: "duration after which the scope expires (e.g. 72h), instead of --not-after",
+				},
+				&cli.BoolFlag{
+					Name:  "url",
+					Usage: "also print a link sharing URL",
+				},
+				&cli.StringFlag{
+					Name:  "url-base",
+					Value: storj.DefaultLinkSharingURL,
+					Usage: "link sharing service used for --url",
+				},
+				&cli.BoolFlag{
+					Name:  "qr",
+					Usage: "also print the scope (or URL with --url) as a QR code",
+				},
+				&cli.StringFlag{
+					Name:  "qr-file",
+					Usage: "write the QR code as PNG image to this file",
+				},
+				configFlag,
+				profileFlag,
+				scopeLogFlag,
+			},
+			//\n arguments- 1. fileName [optional] = storj configuration file, 2. key [optional] = derive the scope from the API key instead of the access grant
+			Action: func(cliContext *cli.Context) error {
+				conf, err := loadConfiguration(cliContext, "storj")
+				if err != nil {
+					return err
+				}
+				keyValue := conf.arg(0)
+
+				options := storj.ShareOptions{
+					ListOnly: cliContext.Bool("list-only"),
+				}
+				if cliContext.IsSet("not-before") {
+					options.NotBefore, err = time.Parse(time.RFC3339, cliContext.String("not-before"))
+					if err != nil {
+						return fmt.Errorf("invalid --not-before: %v", err)
+					}
+				}
+				if cliContext.IsSet("not-after") && cliContext.IsSet("expires-in") {
+					return errors.New("--not-after cannot be combined with --expires-in")
+				}
+				if cliContext.IsSet("not-after") {
+					options.NotAfter, err = time.Parse(time.RFC3339, cliContext.String("not-after"))
+					if err != nil {
+						return fmt.Errorf("invalid --not-after: %v", err)
+					}
+				}
+				if cliContext.IsSet("expires-in") {
+					options.NotAfter = time.Now().Add(cliContext.Duration("expires-in"))
+				}
+
+				account := cliContext.String("account")
+				if conf.profile != nil && !cliContext.IsSet("account") {
+					account = conf.cpanel.UserName
+				}
+				if conf.storj.BucketPerAccount && account == "" {
+					return errors.New("--account is required with bucketPerAccount")
+				}
+				configStorj := conf.storj.ForAccount(account)
+				options.Path = configStorj.UploadPath
+				if cliContext.IsSet("path") {
+					options.Path = cliContext.String("path")
+				}
+
+				scope, info, err := storj.Share(context.Background(), configStorj, keyValue, options)
+				if err != nil {
+					fmt.Println("Error while creating the shared scope:")
+					return err
+				}
+
+				permission := "read-only"
+				if options.ListOnly {
+					permission = "list-only"
+				}
+				fmt.Println(" ")
+				fmt.Printf("Shared %s access to %s/%s\n", permission, configStorj.Bucket, strings.TrimPrefix(options.Path, "/"))
+				if !options.NotBefore.IsZero() {
+					fmt.Println("Valid from\t: ", options.NotBefore.Format(time.RFC3339))
+				}
+				if !options.NotAfter.IsZero() {
+					fmt.Println("Valid until\t: ", options.NotAfter.Format(time.RFC3339))
+				}
+				fmt.Println("Restricted Access Grant: ", scope)
+				recordScope(cliContext.String("scope-log"), "share", scope, info)
+
+				shared := scope
+				if cliContext.Bool("url") {
+					shared = storj.ShareURL(cliContext.String("url-base"), scope, configStorj.Bucket, options.Path)
+					fmt.Println("URL: ", shared)
+				}
+
+				if cliContext.Bool("qr") || cliContext.IsSet("qr-file") {
+					code, err := qrcode.New(shared, qrcode.Low)
+					if err != nil {
+						return fmt.Errorf("could not create QR code: %v", err)
+					}
+					if cliContext.Bool("qr") {
+						fmt.Println(code.ToSmallString(false))
+					}
+					if cliContext.IsSet("qr-file") {
+						if err := code.WriteFile(512, cliContext.String("qr-file")); err != nil {
+							return err
+						}
+						fmt.Println("QR code written to ", cliContext.String("qr-file"))
+					}
+				}
 				fmt.Println(" ")
-				if keyValue == "key" {
-					if restrict == "restrict" {
-						fmt.Println("Restricted Serialized Scope Key: ", scope)
-						fmt.Println(" ")
-					} else {
-						fmt.Println("Serialized Scope Key: ", scope)
-						fmt.Println(" ")
+				return nil
+			},
+		},
+		{
+			Name:  "init",
+			Usage: "Interactively create the cPanel and Storj configuration, checking every answer",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "offline",
+					Usage: "only validate the answers, without connecting to cPanel and the satellite",
+				},
+				configFlag,
+				profileFlag,
+			},
+			//\n arguments- 1. fileName [optional] = cPanel configuration file to write, 2. fileName [optional] = Storj configuration file to write
+			Action: initConfiguration,
+		},
+		{
+			Name:  "config",
+			Usage: "Commands to work with the configuration files",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "check",
+					Usage: "Validate the cPanel and Storj configuration and test the connections with it, reporting every problem found",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "offline",
+							Usage: "only validate the configuration, without connecting to cPanel and the satellite",
+						},
+						configFlag,
+						profileFlag,
+						notifyFlag,
+					},
+					//\n arguments- 1. fileName [optional] = cPanel configuration file, 2. fileName [optional] = Storj configuration file, 3. key [optional] = check the API key instead of the access grant
+					Action: checkConfiguration,
+				},
+				{
+					Name:  "migrate",
+					Usage: "Replace the deprecated serializedScope by the converted accessGrant in the configuration",
+					Flags: []cli.Flag{
+						configFlag,
+						profileFlag,
+					},
+					//\n arguments- 1. fileName [optional] = Storj configuration file
+					Action: migrateConfiguration,
+				},
+			},
+		},
+		{
+			Name:  "scopes",
+			Usage: "Commands to audit and revoke the serialized scopes handed out by store and share",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "list",
+					Usage: "List issued scopes with their restrictions and status",
+					Flags: []cli.Flag{scopeLogFlag},
+					Action: func(cliContext *cli.Context) error {
+						scopeLog, err := state.OpenScopeLog(cliContext.String("scope-log"))
+						if err != nil {
+							return err
+						}
+						scopes := scopeLog.Scopes()
+						if len(scopes) == 0 {
+							fmt.Println("No scopes recorded in", cliContext.String("scope-log"))
+							return nil
+						}
+
+						now := time.Now()
+						writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+						fmt.Fprintln(writer, "FINGERPRINT\tISSUED\tCOMMAND\tSTATUS\tBUCKET/PREFIX\tPERMISSIONS\tNOT BEFORE\tNOT AFTER")
+						for _, scope := range scopes {
+							fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s/%s\t%s\t%s\t%s\n",
+								scope.Fingerprint[:16], scope.Issued.Format(time.RFC3339), scope.Command, scope.Status(now),
+								scope.Bucket, scope.Prefix, permissions(scope), formatOptionalTime(scope.NotBefore), formatOptionalTime(scope.NotAfter))
+						}
+						return writer.Flush()
+					},
+				},
+				{
+					Name:      "revoke",
+					Usage:     "Mark an issued scope as revoked",
+					ArgsUsage: "fingerprint",
+					Flags:     []cli.Flag{scopeLogFlag},
+					Action: func(cliContext *cli.Context) error {
+						if cliContext.Args().Len() != 1 {
+							return errors.New("expected the fingerprint (or a unique prefix of it) of the scope to revoke")
+						}
+						scopeLog, err := state.OpenScopeLog(cliContext.String("scope-log"))
+						if err != nil {
+							return err
+						}
+
+						// The uplink library in use has no way to ask the satellite to reject a scope,
+						// so the revocation is recorded locally only.
+						scope, err := scopeLog.MarkRevoked(cliContext.Args().First(), false, time.Now())
+						if err != nil {
+							return err
+						}
+						fmt.Println("Marked scope", scope.Fingerprint[:16], "as revoked")
+						fmt.Println("NOTE: the satellite does not support revoking scopes; the scope stays usable until it expires.")
+						fmt.Println("To invalidate it immediately, delete the API key it was derived from in the satellite web interface.")
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "history",
+			Usage: "Command to show which backups were uploaded, when and with which result",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "account",
+					Usage: "only show uploads of this cPanel account",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "only show the latest uploads",
+				},
+				stateFlag,
+			},
+			Action: func(cliContext *cli.Context) error {
+				history, err := state.Open(cliContext.String("state"))
+				if err != nil {
+					logger.Error("Could not read upload history", logging.F("file", cliContext.String("state")))
+					return err
+				}
+
+				var uploads []state.Upload
+				for _, upload := range history.History() {
+					if cliContext.IsSet("account") && upload.Account != cliContext.String("account") {
+						continue
 					}
+					uploads = append(uploads, upload)
 				}
-				return err
+				if limit := cliContext.Int("limit"); limit > 0 && len(uploads) > limit {
+					uploads = uploads[len(uploads)-limit:]
+				}
+
+				if len(uploads) == 0 {
+					fmt.Println("No uploads recorded in", cliContext.String("state"))
+					return nil
+				}
+
+				writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(writer, "FINISHED\tRESULT\tACCOUNT\tFILE\tSIZE\tBUCKET\tOBJECT KEY\tSHA-256")
+				for _, upload := range uploads {
+					result := upload.Result
+					if upload.Error != "" {
+						result += ": " + upload.Error
+					}
+					fmt.Fprintf(writer, "%s\t%s\t%s@%s\t%s\t%d\t%s\t%s\t%s\n",
+						upload.Finished.Format(time.RFC3339), result, upload.Account, upload.Host,
+						upload.FileName, upload.Size, upload.Bucket, upload.ObjectKey, upload.SHA256)
+				}
+				return writer.Flush()
 			},
 		},
 	}
 }
 
+// printScope displays the serialized scope created for the upload, if one was requested.
+func printScope(scope string, keyValue string, restrict string) {
+	fmt.Fprintln(output, " ")
+	if keyValue == "key" {
+		if restrict == "restrict" {
+			fmt.Fprintln(output, "Restricted Access Grant: ", scope)
+			fmt.Fprintln(output, " ")
+		} else {
+			fmt.Fprintln(output, "Access Grant: ", scope)
+			fmt.Fprintln(output, " ")
+		}
+	}
+}
+
+// keyFields returns the values used to lay out the object key of a cPanel backup.
+func keyFields(cpanelReader *cpanel.Cpaneldata) storj.KeyFields {
+	return storj.KeyFields{
+		Host:     cpanelReader.Host,
+		Account:  cpanelReader.Account,
+		Type:     storj.BackupTypeFull,
+		FileName: cpanelReader.FileName,
+		Time:     cpanelReader.Time,
+	}
+}
+
+// uploadBackup uploads a cPanel backup to the bucket of the session
+// and records the outcome in the upload history.
+func uploadBackup(ctx context.Context, session *storj.Session, history *state.Store, cpanelReader *cpanel.Cpaneldata, report *runReport) error {
+	upload := state.Upload{
+		Host:      cpanelReader.Host,
+		Account:   cpanelReader.Account,
+		FileName:  cpanelReader.FileName,
+		Size:      cpanelReader.Size,
+		Bucket:    session.Config.Bucket,
+		ObjectKey: session.ObjectKey(keyFields(cpanelReader)),
+		Started:   time.Now(),
+		Result:    state.ResultSuccess,
+	}
+
+	logger.Info("Uploading backup",
+		logging.F("host", upload.Host),
+		logging.F("account", upload.Account),
+		logging.F("file", upload.FileName),
+		logging.F("size", upload.Size),
+		logging.F("key", upload.ObjectKey))
+	result, err := session.Upload(ctx, upload.ObjectKey, cpanelReader.Reader(ctx))
+	upload.Finished = time.Now()
+	upload.SHA256 = result.SHA256
+	if err != nil {
+		upload.Result = state.ResultFailed
+		if ctx.Err() != nil {
+			upload.Result = state.ResultInterrupted
+		}
+		upload.Error = err.Error()
+	}
+
+	report.uploaded(cpanelReader, upload, result.Duration)
+	if recordErr := history.Record(upload); recordErr != nil {
+		logger.Warn("Could not record upload history", logging.F("error", recordErr))
+	}
+	return err
+}
+
+// bandwidthOverride returns the bandwidth limit given with --bwlimit, or nil to use the configured limits.
+func bandwidthOverride(cliContext *cli.Context) (*ratelimit.Config, error) {
+	if !cliContext.IsSet("bwlimit") {
+		return nil, nil
+	}
+	limit := cliContext.String("bwlimit")
+	if _, err := ratelimit.ParseRate(limit); err != nil {
+		return nil, err
+	}
+	return &ratelimit.Config{Limit: limit}, nil
+}
+
+// recordScope adds a handed out serialized scope to the audit log of issued scopes.
+func recordScope(scopeLogFile string, command string, scope string, info storj.ScopeInfo) {
+	if scope == "" {
+		return
+	}
+
+	issued := state.IssuedScope{
+		Fingerprint:     storj.Fingerprint(scope),
+		Issued:          time.Now(),
+		Command:         command,
+		Satellite:       info.Satellite,
+		Bucket:          info.Bucket,
+		Prefix:          info.Prefix,
+		Restricted:      info.Restricted,
+		DisallowReads:   info.DisallowReads,
+		DisallowWrites:  info.DisallowWrites,
+		DisallowLists:   info.DisallowLists,
+		DisallowDeletes: info.DisallowDeletes,
+	}
+	if !info.NotBefore.IsZero() {
+		issued.NotBefore = &info.NotBefore
+	}
+	if !info.NotAfter.IsZero() {
+		issued.NotAfter = &info.NotAfter
+	}
+
+	scopeLog, err := state.OpenScopeLog(scopeLogFile)
+	if err == nil {
+		err = scopeLog.Record(issued)
+	}
+	if err != nil {
+		logger.Warn("Could not record issued scope", logging.F("error", err))
+		return
+	}
+	fmt.Fprintln(output, "Scope fingerprint: ", issued.Fingerprint[:16])
+}
+
+// permissions describes what an issued scope allows.
+func permissions(scope state.IssuedScope) string {
+	if !scope.Restricted {
+		return "full"
+	}
+	var allowed []string
+	if !scope.DisallowReads {
+		allowed = append(allowed, "read")
+	}
+	if !scope.DisallowWrites {
+		allowed = append(allowed, "write")
+	}
+	if !scope.DisallowLists {
+		allowed = append(allowed, "list")
+	}
+	if !scope.DisallowDeletes {
+		allowed = append(allowed, "delete")
+	}
+	if len(allowed) == 0 {
+		return "none"
+	}
+	return strings.Join(allowed, ",")
+}
+
+// formatOptionalTime formats t, or returns "-" when it is not set.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// storeOptions are the options of a run of store.
+type storeOptions struct {
+	keyValue string
+	restrict string
+	backup   cpanel.BackupOptions
+	// allPending uploads every complete backup in the home directory that is not in the bucket yet.
+	allPending bool
+	force      bool
+	// ifDue skips the run when the schedule of the profile says no backup is due.
+	ifDue    bool
+	state    string
+	scopeLog string
+	// lockDir keeps the lock files of the accounts; lockWait is how long to wait for another run.
+	lockDir  string
+	lockWait time.Duration
+	// countBucket counts the objects and bytes in the bucket after the upload, for the metrics.
+	countBucket bool
+	// dryRun prints what the run would upload instead of uploading, see planStore.
+	dryRun bool
+}
+
+// runStore uploads a backup of the cPanel account of conf to its bucket, as selected by options,
+// and adds the outcome to report. The hooks of the profile run around the backup.
+// When ctx is canceled the run stops, aborting an unfinished upload.
+func runStore(ctx context.Context, conf configuration, options storeOptions, report *runReport) (err error) {
+	// Two runs generating backups of the same account at once could upload each other's backup.
+	// A dry run changes nothing and needs no lock.
+	if !options.dryRun {
+		report.Stage = stageLock
+		accountLock, err := lockAccount(ctx, options.lockDir, options.lockWait, conf.cpanel, "store")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = accountLock.Release() }()
+		report.Stage = stageConfig
+	}
+
+	history, err := state.Open(options.state)
+	if err != nil {
+		logger.Error("Could not read upload history", logging.F("file", options.state))
+		return err
+	}
+
+	if options.ifDue {
+		if conf.profile == nil || conf.profile.Schedule.IsZero() {
+			return errors.New("--if-due requires a profile with a schedule")
+		}
+		if last, ok := history.LastSuccess(conf.cpanel.HostName, conf.cpanel.UserName); ok {
+			if next := conf.profile.Schedule.Next(last.Finished); time.Now().Before(next) {
+				logger.Info("No backup due", logging.F("account", conf.cpanel.UserName), logging.F("next", next))
+				report.Result = resultSkipped
+				return nil
+			}
+		}
+	}
+
+	if options.dryRun {
+		return planStore(ctx, conf, options, history, report)
+	}
+
+	storeHooks := newStoreHooks(ctx, conf, report)
+	defer func() {
+		if err != nil {
+			storeHooks.failed(err)
+		}
+	}()
+	if err := storeHooks.preBackup(); err != nil {
+		return err
+	}
+
+	if options.allPending {
+		return storePending(ctx, conf, options, history, storeHooks, report)
+	}
+
+	// Establish connection with cPanel and get io.Reader implementor.
+	report.Stage = stageCPanel
+	cpanelReader, err := cpanel.ConnectToCpanelWithContext(ctx, conf.cpanel, options.backup)
+	if err != nil {
+		logger.Error("Failed to establish connection with cPanel", logging.F("error", err))
+		return err
+	}
+	defer cpanelReader.Close()
+	if err := storeHooks.postBackup(cpanelReader); err != nil {
+		return err
+	}
+
+	if upload, ok := history.Uploaded(cpanelReader.Host, cpanelReader.Account, cpanelReader.FileName, cpanelReader.Size); ok && !options.force {
+		logger.Info("Backup was already uploaded, skipping (use --force to upload again)",
+			logging.F("account", cpanelReader.Account),
+			logging.F("file", cpanelReader.FileName),
+			logging.F("uploaded", upload.Finished),
+			logging.F("key", upload.ObjectKey))
+		report.skipped(cpanelReader, upload)
+		return nil
+	}
+
+	configStorj := conf.storj.ForAccount(cpanelReader.Account)
+
+	report.Stage = stageStorj
+	session, err := storj.OpenSession(ctx, configStorj, options.keyValue, options.restrict)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	// Fetch fullbackup from cPanel instance
+	// and simultaneously store them into desired Storj bucket.
+	report.Stage = stageUpload
+	err = uploadBackup(ctx, session, history, cpanelReader, report)
+	if err != nil {
+		logger.Error("Error while fetching cPanel backup data and uploading them to bucket", logging.F("error", err))
+		return err
+	}
+	if err := storeHooks.postUpload(); err != nil {
+		return err
+	}
+	printScope(session.Scope, options.keyValue, options.restrict)
+	report.issued(session.Scope, session.ScopeInfo, options.restrict)
+	recordScope(options.scopeLog, "store", session.Scope, session.ScopeInfo)
+	if options.countBucket {
+		countBucket(ctx, session, report)
+	}
+	return nil
+}
+
+// storePending uploads every complete backup in the cPanel account's home directory
+// that is neither stored in the Storj bucket nor recorded as uploaded in the history.
+func storePending(ctx context.Context, conf configuration, options storeOptions, history *state.Store, storeHooks *storeHooks, report *runReport) error {
+	configcPanel := conf.cpanel
+	report.Stage = stageCPanel
+	backups, err := cpanel.LocalBackupsWithConfig(configcPanel)
+	if err != nil {
+		logger.Error("Failed to establish connection with cPanel", logging.F("error", err))
+		return err
+	}
+
+	configStorj := conf.storj.ForAccount(configcPanel.UserName)
+
+	report.Stage = stageStorj
+	session, err := storj.OpenSession(ctx, configStorj, options.keyValue, options.restrict)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	objects, err := session.List(ctx, configStorj.AccountPrefix(configcPanel.HostName, configcPanel.UserName))
+	if err != nil {
+		return err
+	}
+	uploaded := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		uploaded[object.Key] = true
+	}
+
+	var pending int
+	for _, backup := range backups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := session.ObjectKey(storj.KeyFields{
+			Host:     configcPanel.HostName,
+			Account:  configcPanel.UserName,
+			Type:     storj.BackupTypeFull,
+			FileName: backup.File,
+			Time:     backup.Time,
+		})
+		if uploaded[key] && !options.force {
+			logger.Info("Backup was already uploaded, skipping", logging.F("account", configcPanel.UserName), logging.F("file", backup.File))
+			report.skippedBackup(configcPanel, backup, configStorj.Bucket, key)
+			continue
+		}
+
+		report.Stage = stageCPanel
+		cpanelReader, err := cpanel.OpenBackup(configcPanel, backup)
+		if err != nil {
+			return err
+		}
+		if err := storeHooks.postBackup(cpanelReader); err != nil {
+			cpanelReader.Close()
+			return err
+		}
+		if upload, ok := history.Uploaded(cpanelReader.Host, cpanelReader.Account, cpanelReader.FileName, cpanelReader.Size); ok && !options.force {
+			logger.Info("Backup was already uploaded, skipping", logging.F("account", configcPanel.UserName), logging.F("file", backup.File))
+			report.skipped(cpanelReader, upload)
+			cpanelReader.Close()
+			continue
+		}
+		pending++
+
+		report.Stage = stageUpload
+		err = uploadBackup(ctx, session, history, cpanelReader, report)
+		cpanelReader.Close()
+		if err != nil {
+			logger.Error("Error while uploading cPanel backup data to bucket", logging.F("error", err))
+			return err
+		}
+		if err := storeHooks.postUpload(); err != nil {
+			return err
+		}
+	}
+	logger.Info("Uploaded pending backups",
+		logging.F("account", configcPanel.UserName),
+		logging.F("uploaded", pending),
+		logging.F("backups", len(backups)))
+
+	printScope(session.Scope, options.keyValue, options.restrict)
+	report.issued(session.Scope, session.ScopeInfo, options.restrict)
+	recordScope(options.scopeLog, "store", session.Scope, session.ScopeInfo)
+	if options.countBucket {
+		countBucket(ctx, session, report)
+	}
+	return nil
+}
+
 func main() {
 
 	setAppInfo()
 
 	setCommands()
 
-	err := app.Run(os.Args)
+	err := app.RunContext(handleSignals(), os.Args)
 
+	if logFile != nil {
+		if err != nil {
+			logger.Error("Command failed", logging.F("error", err))
+		}
+		logFile.Close()
+	}
 	if err != nil {
 		log.Fatalf("app.Run: %s", err)
 	}
@@ -0,0 +1,145 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/storj"
+
+	"github.com/urfave/cli"
+)
+
+// verifyFlags configure the verify command.
+var verifyFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "object",
+		Usage: "verify the object with key `KEY` (default: the latest backup of the account)",
+	},
+	&cli.BoolFlag{
+		Name:  "all",
+		Usage: "verify every backup of the account instead of the latest one",
+	},
+	configFlag,
+	profileFlag,
+	bwlimitFlag,
+	reportFlag,
+	reportFileFlag,
+	notifyFlag,
+}
+
+// runVerify downloads backups of the configured account without storing them
+// and checks them against the hashes stored with them.
+// Every backup is verified even when one fails, the run fails if any did.
+func runVerify(cliContext *cli.Context) (err error) {
+	if err := checkReportFlags(cliContext); err != nil {
+		return err
+	}
+	notifications, err := loadNotifications(cliContext)
+	if err != nil {
+		return err
+	}
+	report := newRunReport("verify")
+	defer func() {
+		err = report.finish(cliContext, err)
+		notifyRun(notifications, report)
+	}()
+
+	conf, err := loadConfiguration(cliContext, "cpanel", "storj")
+	if err != nil {
+		return err
+	}
+	bandwidth, err := bandwidthOverride(cliContext)
+	if err != nil {
+		return err
+	}
+	conf.limitBandwidth(bandwidth)
+	report.Host, report.Account = conf.cpanel.HostName, conf.cpanel.UserName
+
+	report.Stage = stageStorj
+	configStorj := conf.storj.ForAccount(conf.cpanel.UserName)
+	ctx := cliContext.Context
+	session, err := storj.OpenSessionReadOnly(ctx, configStorj, conf.arg(0), "")
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var objects []storj.Object
+	switch {
+	case cliContext.IsSet("object"):
+		objects = append(objects, storj.Object{Key: cliContext.String("object")})
+	case cliContext.Bool("all"):
+		listed, err := session.List(ctx, configStorj.AccountPrefix(conf.cpanel.HostName, conf.cpanel.UserName))
+		if err != nil {
+			return err
+		}
+		for _, object := range listed {
+			if _, ok := configStorj.AccountBackup(object.Key, conf.cpanel.HostName, conf.cpanel.UserName); ok {
+				objects = append(objects, object)
+			}
+		}
+		if len(objects) == 0 {
+			return fmt.Errorf("no backup of %s@%s in bucket %q", conf.cpanel.UserName, conf.cpanel.HostName, configStorj.Bucket)
+		}
+	default:
+		latest, err := session.LatestBackup(ctx, conf.cpanel.HostName, conf.cpanel.UserName)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, latest)
+	}
+
+	report.Stage = stageVerify
+	failed := 0
+	for _, object := range objects {
+		result, err := session.DownloadObject(ctx, object.Key, ioutil.Discard, nil)
+		report.verified(conf.cpanel.HostName, conf.cpanel.UserName, configStorj, result, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			logger.Error("Backup failed verification", logging.F("key", object.Key), logging.F("error", err))
+			failed++
+		}
+	}
+	logger.Info("Verified backups",
+		logging.F("account", conf.cpanel.UserName),
+		logging.F("backups", len(objects)),
+		logging.F("failed", failed))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backup(s) failed verification", failed, len(objects))
+	}
+	return nil
+}
+
+// verified adds the outcome of the verification of a backup by its download result.
+func (report *runReport) verified(host, account string, configStorj storj.ConfigStorj, result storj.DownloadResult, err error) {
+	fields, ok := configStorj.ParseObjectKey(result.Key)
+	if !ok {
+		fields.FileName = path.Base(result.Key)
+	}
+	backup := backupReport{
+		Host:       host,
+		Account:    account,
+		FileName:   fields.FileName,
+		BackupTime: fields.Time,
+		Bucket:     configStorj.Bucket,
+		ObjectKey:  result.Key,
+		Bytes:      result.Bytes,
+		SHA256:     result.SHA256,
+		Result:     resultVerified,
+	}
+	switch {
+	case err != nil:
+		backup.Result = resultFailed
+		backup.Error = err.Error()
+	case !result.Verified:
+		backup.Result = resultUnverified
+	}
+	report.Backups = append(report.Backups, backup)
+}
@@ -0,0 +1,241 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"utropicmedia/cpanel_storj_interface/cpanel"
+	"utropicmedia/cpanel_storj_interface/hooks"
+	"utropicmedia/cpanel_storj_interface/notify"
+	"utropicmedia/cpanel_storj_interface/storj"
+)
+
+// File is the configuration file combining cPanel sources and Storj destinations in named profiles.
+type File struct {
+	// DefaultProfile is used when no profile is selected.
+	DefaultProfile string             `json:"defaultProfile"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// Profile backs up one cPanel account to one Storj bucket.
+type Profile struct {
+	// Name is the key of the profile in the configuration file.
+	Name string `json:"-"`
+
+	CPanel    cpanel.ConfigcPanel `json:"cpanel"`
+	Storj     storj.ConfigStorj   `json:"storj"`
+	Retention Retention           `json:"retention"`
+	Schedule  Schedule            `json:"schedule"`
+	// Hooks run commands around the backups of the profile.
+	Hooks hooks.Config `json:"hooks"`
+}
+
+// Load reads and validates the configuration file at path.
+// All problems found are returned at once as Errors, located by line and column.
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse validates and decodes the configuration file contents data, read from the named file.
+func Parse(name string, data []byte) (*File, error) {
+	var file File
+	err := decode(name, data, &file, func(v *validator) {
+		for profileName, profile := range file.Profiles {
+			profile.Name = profileName
+			file.Profiles[profileName] = profile
+		}
+		file.validate(v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// LoadCPanel reads and validates a separate cPanel configuration file, like cpanel_property.json,
+// as strictly as the configuration file with profiles.
+func LoadCPanel(path string) (cpanel.ConfigcPanel, error) {
+	var configcPanel cpanel.ConfigcPanel
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return configcPanel, err
+	}
+	err = decode(path, data, &configcPanel, func(v *validator) {
+		v.cpanel("", configcPanel)
+	})
+	return configcPanel, err
+}
+
+// LoadStorj reads and validates a separate Storj configuration file, like storj_config.json,
+// as strictly as the configuration file with profiles.
+func LoadStorj(path string) (storj.ConfigStorj, error) {
+	var configStorj storj.ConfigStorj
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return configStorj, err
+	}
+	err = decode(path, data, &configStorj, func(v *validator) {
+		v.storj("", configStorj)
+	})
+	return configStorj, err
+}
+
+// LoadNotifications reads and validates the notification configuration file at path,
+// as strictly as the configuration file with profiles.
+func LoadNotifications(path string) (notify.Config, error) {
+	var configNotify notify.Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return configNotify, err
+	}
+	err = decode(path, data, &configNotify, func(v *validator) {
+		for _, problem := range configNotify.Check() {
+			v.errorf(problem.Field, "%s", problem.Message)
+		}
+	})
+	return configNotify, err
+}
+
+// decode checks data strictly, decodes it into the value v points to
+// and then validates the decoded values with validate.
+func decode(name string, data []byte, v interface{}, validate func(v *validator)) error {
+	offsets, errs := checkStrict(name, data, reflect.TypeOf(v).Elem())
+	if err := json.Unmarshal(data, v); err != nil {
+		if len(errs) > 0 {
+			return errs
+		}
+		return err
+	}
+
+	// Unknown and duplicate fields do not keep the values from being decoded,
+	// so the values are validated as well to report every problem at once.
+	checker := validator{file: name, data: data, offsets: offsets, errs: errs}
+	validate(&checker)
+	if len(checker.errs) > 0 {
+		return checker.errs
+	}
+	return nil
+}
+
+// Profile returns the named profile. Without name it returns the default profile,
+// or the only profile of the file.
+func (file *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = file.DefaultProfile
+	}
+	if name == "" {
+		if len(file.Profiles) != 1 {
+			return Profile{}, fmt.Errorf("select one of the profiles %s or set defaultProfile", strings.Join(file.ProfileNames(), ", "))
+		}
+		for _, profile := range file.Profiles {
+			return profile, nil
+		}
+	}
+
+	profile, ok := file.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(file.ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of all profiles in alphabetical order.
+func (file *File) ProfileNames() []string {
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validator collects problems with the values of a configuration file,
+// located at the key of the offending field.
+type validator struct {
+	file    string
+	data    []byte
+	offsets map[string]int
+	errs    Errors
+}
+
+// errorf reports a problem with the field at path. Fields missing from the file
+// are located at the closest enclosing field that is present.
+func (v *validator) errorf(path string, format string, args ...interface{}) {
+	offset := 0
+	for p := path; p != ""; {
+		if o, ok := v.offsets[strings.ToLower(p)]; ok {
+			offset = o
+			break
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	line, column := position(v.data, offset)
+	v.errs = append(v.errs, &Error{
+		File:    v.file,
+		Line:    line,
+		Column:  column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (file *File) validate(v *validator) {
+	if len(file.Profiles) == 0 {
+		v.errorf("profiles", "no profiles are configured")
+	}
+	if file.DefaultProfile != "" {
+		if _, ok := file.Profiles[file.DefaultProfile]; !ok {
+			v.errorf("defaultProfile", "unknown profile %q", file.DefaultProfile)
+		}
+	}
+	for _, name := range file.ProfileNames() {
+		file.Profiles[name].validate(v, "profiles."+name)
+	}
+}
+
+func (profile Profile) validate(v *validator, path string) {
+	v.cpanel(path+".cpanel", profile.CPanel)
+	v.storj(path+".storj", profile.Storj)
+
+	if profile.Retention.KeepLast < 0 {
+		v.errorf(path+".retention.keepLast", "must not be negative")
+	}
+	if profile.Retention.KeepDays < 0 {
+		v.errorf(path+".retention.keepDays", "must not be negative")
+	}
+	if err := profile.Schedule.check(); err != nil {
+		v.errorf(path+".schedule", "%v", err)
+	}
+	for _, problem := range profile.Hooks.Check() {
+		v.errorf(path+".hooks."+problem.Field, "%s", problem.Message)
+	}
+}
+
+// cpanel reports the problems of the cPanel configuration at path.
+func (v *validator) cpanel(path string, configcPanel cpanel.ConfigcPanel) {
+	for _, problem := range configcPanel.Check() {
+		v.errorf(joinPath(path, problem.Field), "%s", problem.Message)
+	}
+}
+
+// storj reports the problems of the Storj configuration at path.
+func (v *validator) storj(path string, configStorj storj.ConfigStorj) {
+	for _, problem := range configStorj.Check() {
+		v.errorf(joinPath(path, problem.Field), "%s", problem.Message)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/storj"
+)
+
+// Retention decides which backups of an account are kept in the bucket.
+// A backup is kept when either rule keeps it; without rules all backups are kept.
+type Retention struct {
+	// KeepLast keeps the given number of newest backups.
+	KeepLast int `json:"keepLast"`
+	// KeepDays keeps the backups uploaded within the given number of days.
+	KeepDays int `json:"keepDays"`
+}
+
+// IsZero reports whether no retention rule is configured.
+func (retention Retention) IsZero() bool {
+	return retention.KeepLast == 0 && retention.KeepDays == 0
+}
+
+// Expired returns the backups among objects that are not kept, oldest first.
+func (retention Retention) Expired(objects []storj.Object, now time.Time) []storj.Object {
+	if retention.IsZero() {
+		return nil
+	}
+
+	sorted := append([]storj.Object(nil), objects...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Created.After(sorted[j].Created)
+	})
+
+	var expired []storj.Object
+	cutoff := now.AddDate(0, 0, -retention.KeepDays)
+	for i, object := range sorted {
+		if i < retention.KeepLast {
+			continue
+		}
+		if retention.KeepDays > 0 && object.Created.After(cutoff) {
+			continue
+		}
+		expired = append(expired, object)
+	}
+
+	// Oldest first.
+	for i, j := 0, len(expired)-1; i < j; i, j = i+1, j-1 {
+		expired[i], expired[j] = expired[j], expired[i]
+	}
+	return expired
+}
+
+// Schedule is how often a profile is backed up.
+type Schedule struct {
+	// Every is the interval between two backups, e.g. "24h" or "168h".
+	Every string `json:"every"`
+	// At is the local time of the day, "HH:MM", at which backups are made.
+	// It requires Every to be a whole number of days.
+	At string `json:"at"`
+}
+
+// IsZero reports whether no schedule is configured.
+func (schedule Schedule) IsZero() bool {
+	return schedule.Every == "" && schedule.At == ""
+}
+
+// parse returns the interval and, if At is set, the time of day.
+func (schedule Schedule) parse() (every time.Duration, at time.Duration, err error) {
+	if schedule.Every == "" {
+		return 0, 0, errors.New("every is required")
+	}
+	every, err = time.ParseDuration(schedule.Every)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid interval %q, expected e.g. \"24h\"", schedule.Every)
+	}
+	if every <= 0 {
+		return 0, 0, fmt.Errorf("interval %q must be positive", schedule.Every)
+	}
+
+	if schedule.At == "" {
+		return every, -1, nil
+	}
+	t, err := time.Parse("15:04", schedule.At)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q, expected HH:MM", schedule.At)
+	}
+	if every%(24*time.Hour) != 0 {
+		return 0, 0, fmt.Errorf("interval %q must be a whole number of days when at is set", schedule.Every)
+	}
+	return every, time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (schedule Schedule) check() error {
+	if schedule.IsZero() {
+		return nil
+	}
+	_, _, err := schedule.parse()
+	return err
+}
+
+// Next returns when the backup following one made at last is due.
+// Without a previous backup, or without a schedule, a backup is due right away.
+func (schedule Schedule) Next(last time.Time) time.Time {
+	if schedule.IsZero() || last.IsZero() {
+		return last
+	}
+	every, at, err := schedule.parse()
+	if err != nil {
+		return last
+	}
+	if at < 0 {
+		return last.Add(every)
+	}
+	last = last.Local()
+	day := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.Local)
+	return day.AddDate(0, 0, int(every/(24*time.Hour))).Add(at)
+}
+
+// Due reports whether a backup is due at now when the previous one was made at last.
+func (schedule Schedule) Due(last, now time.Time) bool {
+	return !now.Before(schedule.Next(last))
+}
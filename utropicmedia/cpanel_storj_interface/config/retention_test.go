@@ -0,0 +1,108 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/storj"
+)
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(key string, days int) storj.Object {
+		return storj.Object{Key: key, Created: now.AddDate(0, 0, -days)}
+	}
+	// Listed in no particular order, as the bucket returns them.
+	objects := []storj.Object{
+		daysAgo("d3", 3),
+		daysAgo("d30", 30),
+		daysAgo("d1", 1),
+		daysAgo("d10", 10),
+		daysAgo("d6", 6),
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		objects   []storj.Object
+		expired   []string
+	}{
+		{"no rules keep everything", Retention{}, objects, nil},
+		{"no backups", Retention{KeepLast: 1}, nil, nil},
+		{"keep last", Retention{KeepLast: 2}, objects, []string{"d30", "d10", "d6"}},
+		{"keep last more than there are", Retention{KeepLast: 10}, objects, nil},
+		{"keep days", Retention{KeepDays: 7}, objects, []string{"d30", "d10"}},
+		{"either rule keeps", Retention{KeepLast: 4, KeepDays: 2}, objects, []string{"d30"}},
+		{"keep days beyond keep last", Retention{KeepLast: 1, KeepDays: 7}, objects, []string{"d30", "d10"}},
+		{"cutoff is exclusive", Retention{KeepDays: 3}, objects, []string{"d30", "d10", "d6", "d3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var expired []string
+			for _, object := range test.retention.Expired(test.objects, now) {
+				expired = append(expired, object.Key)
+			}
+			if !reflect.DeepEqual(expired, test.expired) {
+				t.Errorf("Expired() = %v, want %v", expired, test.expired)
+			}
+		})
+	}
+}
+
+func TestRetentionExpiredKeepsInput(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	objects := []storj.Object{
+		{Key: "old", Created: now.AddDate(0, 0, -2)},
+		{Key: "new", Created: now},
+	}
+	Retention{KeepLast: 1}.Expired(objects, now)
+	if objects[0].Key != "old" || objects[1].Key != "new" {
+		t.Errorf("Expired() reordered its input: %v", objects)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	last := time.Date(2020, 2, 27, 22, 30, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		schedule Schedule
+		next     time.Time
+	}{
+		{"no schedule", Schedule{}, last},
+		{"interval", Schedule{Every: "12h"}, last.Add(12 * time.Hour)},
+		{"daily at", Schedule{Every: "24h", At: "03:00"}, time.Date(2020, 2, 28, 3, 0, 0, 0, time.Local)},
+		{"weekly at", Schedule{Every: "168h", At: "01:15"}, time.Date(2020, 3, 5, 1, 15, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if next := test.schedule.Next(last); !next.Equal(test.next) {
+				t.Errorf("Next() = %v, want %v", next, test.next)
+			}
+		})
+	}
+}
+
+func TestScheduleCheck(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		valid    bool
+	}{
+		{Schedule{}, true},
+		{Schedule{Every: "24h"}, true},
+		{Schedule{Every: "48h", At: "23:59"}, true},
+		{Schedule{At: "03:00"}, false},
+		{Schedule{Every: "daily"}, false},
+		{Schedule{Every: "-1h"}, false},
+		{Schedule{Every: "12h", At: "03:00"}, false},
+		{Schedule{Every: "24h", At: "3am"}, false},
+	}
+	for _, test := range tests {
+		if err := test.schedule.check(); (err == nil) != test.valid {
+			t.Errorf("check() of %+v = %v, want valid %v", test.schedule, err, test.valid)
+		}
+	}
+}
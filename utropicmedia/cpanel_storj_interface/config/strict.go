@@ -0,0 +1,366 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is a problem found in a configuration file, located by line and column.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	// Path is the dotted path of the offending field, e.g. "profiles.main.storj.bucketName".
+	Path    string
+	Message string
+}
+
+func (err *Error) Error() string {
+	location := fmt.Sprintf("%s:%d:%d", err.File, err.Line, err.Column)
+	if err.Path == "" {
+		return location + ": " + err.Message
+	}
+	return location + ": " + err.Path + ": " + err.Message
+}
+
+// Errors lists every problem found in a configuration file.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checker walks the JSON tokens of a configuration file and compares them
+// with the fields of the Go value the file is decoded into.
+type checker struct {
+	file    string
+	data    []byte
+	decoder *json.Decoder
+	errs    Errors
+	// offsets maps the lower case path of every field to the offset of its key,
+	// so that later validation errors can point to the field.
+	offsets map[string]int
+}
+
+// checkStrict reports unknown and duplicate fields, values of the wrong type and syntax errors
+// in data, which is about to be decoded into a value of type t.
+func checkStrict(file string, data []byte, t reflect.Type) (map[string]int, Errors) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	c := &checker{
+		file:    file,
+		data:    data,
+		decoder: decoder,
+		offsets: make(map[string]int),
+	}
+
+	if err := c.value("", t); err != nil {
+		c.syntaxError(err)
+		return c.offsets, c.errs
+	}
+	offset := c.next()
+	if _, err := decoder.Token(); err != io.EOF {
+		c.errorAt(offset, "", "unexpected data after the configuration")
+	}
+	return c.offsets, c.errs
+}
+
+// position converts a byte offset into a line and column, both counting from 1.
+func position(data []byte, offset int) (line, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	column = offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
+
+func (c *checker) errorAt(offset int, path, format string, args ...interface{}) {
+	line, column := position(c.data, offset)
+	c.errs = append(c.errs, &Error{
+		File:    c.file,
+		Line:    line,
+		Column:  column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) syntaxError(err error) {
+	offset := int(c.decoder.InputOffset())
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		// The offending character is the last one read. The decoder reports a file ending
+		// within a value as a syntax error as well, which only its message tells apart.
+		offset = int(syntaxErr.Offset) - 1
+		if syntaxErr.Error() == "unexpected end of JSON input" {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.errorAt(len(c.data), "", "unexpected end of file")
+		return
+	}
+	c.errorAt(offset, "", "%v", err)
+}
+
+// next returns the offset at which the next token starts.
+func (c *checker) next() int {
+	offset := int(c.decoder.InputOffset())
+	for offset < len(c.data) {
+		switch c.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+			continue
+		}
+		break
+	}
+	return offset
+}
+
+// value checks the next JSON value, which is decoded into a value of type t.
+// It only returns syntax errors, problems with the content are collected in c.errs.
+func (c *checker) value(path string, t reflect.Type) error {
+	offset := c.next()
+	token, err := c.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		// null leaves the field unchanged.
+		return nil
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if token != json.Delim('{') {
+			return c.mismatch(offset, path, "an object", token)
+		}
+		return c.object(path, t)
+	case reflect.Map:
+		if token != json.Delim('{') {
+			return c.mismatch(offset, path, "an object", token)
+		}
+		return c.mapEntries(path, t.Elem())
+	case reflect.Slice, reflect.Array:
+		if token != json.Delim('[') {
+			return c.mismatch(offset, path, "a list", token)
+		}
+		for i := 0; c.decoder.More(); i++ {
+			if err := c.value(fmt.Sprintf("%s[%d]", path, i), t.Elem()); err != nil {
+				return err
+			}
+		}
+		_, err := c.decoder.Token()
+		return err
+	case reflect.String:
+		if _, ok := token.(string); !ok {
+			return c.mismatch(offset, path, "a string", token)
+		}
+	case reflect.Bool:
+		if _, ok := token.(bool); !ok {
+			return c.mismatch(offset, path, "true or false", token)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := token.(json.Number)
+		if !ok {
+			return c.mismatch(offset, path, "a number", token)
+		}
+		if _, err := strconv.ParseInt(string(number), 10, t.Bits()); err != nil {
+			c.errorAt(offset, path, "expected a whole number, found %s", number)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := token.(json.Number)
+		if !ok {
+			return c.mismatch(offset, path, "a number", token)
+		}
+		if _, err := strconv.ParseUint(string(number), 10, t.Bits()); err != nil {
+			c.errorAt(offset, path, "expected a non-negative whole number, found %s", number)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := token.(json.Number); !ok {
+			return c.mismatch(offset, path, "a number", token)
+		}
+	default:
+		// Interfaces and other types accept any value.
+		return c.skip(token)
+	}
+	return nil
+}
+
+// object checks the fields of a JSON object decoded into the struct type t.
+func (c *checker) object(path string, t reflect.Type) error {
+	fields := structFields(t)
+	seen := make(map[string]bool)
+	for c.decoder.More() {
+		offset := c.next()
+		token, err := c.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		fieldPath := joinPath(path, key)
+		lower := strings.ToLower(key)
+
+		field, ok := fields[lower]
+		switch {
+		case !ok:
+			c.errorAt(offset, fieldPath, "unknown field, expected one of %s", fieldNames(t))
+			if err := c.skipValue(); err != nil {
+				return err
+			}
+			continue
+		case seen[lower]:
+			c.errorAt(offset, fieldPath, "field is set more than once")
+		}
+		seen[lower] = true
+		c.offsets[strings.ToLower(fieldPath)] = offset
+
+		if err := c.value(fieldPath, field); err != nil {
+			return err
+		}
+	}
+	_, err := c.decoder.Token()
+	return err
+}
+
+// mapEntries checks the entries of a JSON object decoded into a map with values of type elem.
+func (c *checker) mapEntries(path string, elem reflect.Type) error {
+	seen := make(map[string]bool)
+	for c.decoder.More() {
+		offset := c.next()
+		token, err := c.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		entryPath := joinPath(path, key)
+		if seen[key] {
+			c.errorAt(offset, entryPath, "entry is set more than once")
+		}
+		seen[key] = true
+		c.offsets[strings.ToLower(entryPath)] = offset
+
+		if err := c.value(entryPath, elem); err != nil {
+			return err
+		}
+	}
+	_, err := c.decoder.Token()
+	return err
+}
+
+// mismatch reports a value of the wrong type and skips it.
+func (c *checker) mismatch(offset int, path, expected string, token json.Token) error {
+	c.errorAt(offset, path, "expected %s, found %s", expected, describe(token))
+	return c.skip(token)
+}
+
+// skipValue skips the next JSON value.
+func (c *checker) skipValue() error {
+	token, err := c.decoder.Token()
+	if err != nil {
+		return err
+	}
+	return c.skip(token)
+}
+
+// skip skips the rest of the JSON value that starts with token.
+func (c *checker) skip(token json.Token) error {
+	if token != json.Delim('{') && token != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		token, err := c.decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// structFields maps the lower case JSON names of the fields of t to their types.
+// encoding/json matches field names case-insensitively, so the checker does as well.
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+// fieldNames lists the JSON names of the fields of t, for error messages.
+func fieldNames(t reflect.Type) string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || jsonName(field) == "-" {
+			continue
+		}
+		names = append(names, jsonName(field))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describe names the kind of a JSON token for error messages.
+func describe(token json.Token) string {
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return "an object"
+		}
+		return "a list"
+	case string:
+		return strconv.Quote(value)
+	case json.Number:
+		return string(value)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return fmt.Sprint(token)
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type strictNested struct {
+	Value uint8 `json:"value"`
+}
+
+type strictConfig struct {
+	Name    string         `json:"name"`
+	Count   int            `json:"count"`
+	Enabled bool           `json:"enabled"`
+	Rate    float64        `json:"rate"`
+	Limit   *int           `json:"limit"`
+	Tags    []string       `json:"tags"`
+	Nested  strictNested   `json:"nested"`
+	Entries map[string]int `json:"entries"`
+	Any     interface{}    `json:"any"`
+	Ignored string         `json:"-"`
+}
+
+func TestCheckStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		errs []string
+	}{
+		{
+			"valid",
+			`{"name": "a", "count": 2, "enabled": true, "rate": 1.5, "limit": 3, "tags": ["x"],
+			  "nested": {"value": 255}, "entries": {"a": 1}, "any": {"x": [1, {}]}}`,
+			nil,
+		},
+		{"empty", `{}`, nil},
+		{"null leaves a field unchanged", `{"name": null, "nested": null, "limit": null}`, nil},
+		{"field names are case-insensitive", `{"Name": "a", "ENABLED": false}`, nil},
+		{
+			"unknown field",
+			`{"name": "a", "nmae": "b"}`,
+			[]string{"test.json:1:15: nmae: unknown field, expected one of any, count, enabled, entries, limit, name, nested, rate, tags"},
+		},
+		{
+			"ignored field is unknown",
+			`{"Ignored": "x"}`,
+			[]string{"test.json:1:2: Ignored: unknown field, expected one of any, count, enabled, entries, limit, name, nested, rate, tags"},
+		},
+		{
+			"duplicate field",
+			"{\n  \"name\": \"a\",\n  \"Name\": \"b\"\n}",
+			[]string{"test.json:3:3: Name: field is set more than once"},
+		},
+		{
+			"duplicate map entry",
+			`{"entries": {"a": 1, "a": 2}}`,
+			[]string{"test.json:1:22: entries.a: entry is set more than once"},
+		},
+		{
+			"wrong types",
+			"{\n\"name\": 1,\n\"count\": \"2\",\n\"enabled\": \"false\",\n\"rate\": true,\n\"tags\": \"x\",\n\"nested\": [],\n\"entries\": {\"a\": \"b\"}\n}",
+			[]string{
+				"test.json:2:9: name: expected a string, found 1",
+				`test.json:3:10: count: expected a number, found "2"`,
+				`test.json:4:12: enabled: expected true or false, found "false"`,
+				"test.json:5:9: rate: expected a number, found true",
+				`test.json:6:9: tags: expected a list, found "x"`,
+				"test.json:7:11: nested: expected an object, found a list",
+				`test.json:8:18: entries.a: expected a number, found "b"`,
+			},
+		},
+		{
+			"numbers out of range",
+			`{"count": 1.5, "nested": {"value": 256}, "limit": -1}`,
+			[]string{
+				"test.json:1:11: count: expected a whole number, found 1.5",
+				"test.json:1:36: nested.value: expected a non-negative whole number, found 256",
+			},
+		},
+		{
+			"list elements",
+			`{"tags": ["a", 2]}`,
+			[]string{"test.json:1:16: tags[1]: expected a string, found 2"},
+		},
+		{
+			"unknown nested field",
+			`{"nested": {"other": {"deep": [1]}}, "name": 1}`,
+			[]string{
+				"test.json:1:13: nested.other: unknown field, expected one of value",
+				"test.json:1:46: name: expected a string, found 1",
+			},
+		},
+		{"not an object", `[]`, []string{"test.json:1:1: expected an object, found a list"}},
+		{"syntax error", "{\n\"name\": \"a\",,\n}", []string{"test.json:2:13: invalid character ',' looking for beginning of value"}},
+		{"unexpected end of file", `{"name": "a"`, []string{"test.json:1:13: unexpected end of file"}},
+		{"invalid last character", `{"name": x`, []string{"test.json:1:10: invalid character 'x' looking for beginning of value"}},
+		{"data after the configuration", `{} {}`, []string{"test.json:1:4: unexpected data after the configuration"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, errs := checkStrict("test.json", []byte(test.data), reflect.TypeOf(strictConfig{}))
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+			if !reflect.DeepEqual(messages, test.errs) {
+				t.Errorf("checkStrict() errors\n  %s\nwant\n  %s", strings.Join(messages, "\n  "), strings.Join(test.errs, "\n  "))
+			}
+		})
+	}
+}
+
+func TestPosition(t *testing.T) {
+	data := []byte("ab\ncd\n\nef")
+	tests := []struct {
+		offset, line, column int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{4, 2, 2},
+		{6, 3, 1},
+		{7, 4, 1},
+		{100, 4, 3},
+	}
+	for _, test := range tests {
+		if line, column := position(data, test.offset); line != test.line || column != test.column {
+			t.Errorf("position(%d) = %d:%d, want %d:%d", test.offset, line, column, test.line, test.column)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cpanel
+
+import (
+	"time"
+)
+
+// Backup statuses reported by Backups::listfullbackups.
+const (
+	BackupStatusInProgress = "inprogress"
+	BackupStatusComplete   = "complete"
+)
+
+// BackupAPI wraps the cPanel Backup (UAPI) and Backups (API2) modules.
+type BackupAPI struct {
+	gw APIGateway
+}
+
+// FullBackup describes a full backup file in the account's home directory.
+type FullBackup struct {
+	File      string
+	Status    string
+	Time      time.Time
+	Localtime string
+	Reason    string
+}
+
+// Complete reports whether cPanel has finished writing the backup file.
+func (b FullBackup) Complete() bool {
+	return b.Status == BackupStatusComplete
+}
+
+// FullBackupToHomedir starts a full backup of the account into its home directory
+// and returns the PID of the backup process. The backup runs asynchronously;
+// use List to follow its progress. A notification is sent to email if it is not empty.
+func (b BackupAPI) FullBackupToHomedir(email string) (string, error) {
+	var out FullBackuptoHomeDirAPIResponse
+	err := b.gw.UAPI("Backup", "fullbackup_to_homedir", Args{
+		"email": email,
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.Data.PID, nil
+}
+
+// List returns the full backups of the account, oldest first.
+func (b BackupAPI) List() ([]FullBackup, error) {
+	var out ListfullbackupsApiResponse
+	err := b.gw.API2("Backups", "listfullbackups", Args{}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]FullBackup, 0, len(out.Data))
+	for _, entry := range out.Data {
+		backups = append(backups, FullBackup{
+			File:      entry.File,
+			Status:    entry.Status,
+			Time:      time.Unix(int64(entry.Time), 0),
+			Localtime: entry.Localtime,
+			Reason:    entry.Reason,
+		})
+	}
+	return backups, nil
+}
+
+// Restore restores the files of the home directory from a backup archive
+// that was uploaded to the account, given by its path on the cPanel server.
+func (b BackupAPI) Restore(backupPath string) ([]string, error) {
+	var out BaseUAPIResponse
+	err := b.gw.UAPI("Backup", "restore_files", Args{
+		"backup":  backupPath,
+		"verbose": 1,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Messages, nil
+}
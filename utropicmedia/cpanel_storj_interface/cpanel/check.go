@@ -0,0 +1,96 @@
+package cpanel
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"utropicmedia/cpanel_storj_interface/ratelimit"
+)
+
+// ConfigError is a problem with a field of the cPanel configuration.
+type ConfigError struct {
+	// Field is the JSON name of the field, e.g. "hostname" or "http.timeoutSeconds".
+	Field   string
+	Message string
+}
+
+func (err ConfigError) Error() string {
+	return err.Field + ": " + err.Message
+}
+
+// Check validates the configuration without connecting to cPanel
+// and returns every problem found.
+func (config ConfigcPanel) Check() []ConfigError {
+	var problems []ConfigError
+	report := func(field, format string, args ...interface{}) {
+		problems = append(problems, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case config.HostName == "":
+		report("hostname", "is required")
+	case strings.ContainsAny(config.HostName, ":/ "):
+		report("hostname", "expected a host name without scheme, port or path, found %q", config.HostName)
+	}
+	if config.UserName == "" {
+		report("username", "is required")
+	}
+	if config.Password == "" {
+		report("password", "is required")
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			report("caFile", "%v", err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			report("caFile", "no certificates found in %s", config.CAFile)
+		}
+	}
+	if config.PinnedCertSHA256 != "" {
+		if _, err := parseFingerprint(config.PinnedCertSHA256); err != nil {
+			report("pinnedCertSHA256", "%v", err)
+		}
+	}
+	if config.Insecure && (config.CAFile != "" || config.PinnedCertSHA256 != "") {
+		report("insecure", "disables certificate verification, so caFile and pinnedCertSHA256 are ignored")
+	}
+
+	for _, option := range []struct {
+		field string
+		value int
+	}{
+		{"http.timeoutSeconds", config.HTTP.TimeoutSeconds},
+		{"http.idleConnTimeoutSeconds", config.HTTP.IdleConnTimeoutSeconds},
+		{"http.maxIdleConnsPerHost", config.HTTP.MaxIdleConnsPerHost},
+		{"http.maxConnsPerHost", config.HTTP.MaxConnsPerHost},
+		{"backupTimeoutMinutes", config.BackupTimeoutMinutes},
+	} {
+		if option.value < 0 {
+			report(option.field, "must not be negative")
+		}
+	}
+	if config.SpaceMarginPercent != nil && *config.SpaceMarginPercent < 0 {
+		report("spaceMarginPercent", "must not be negative")
+	}
+	if _, err := ratelimit.New(config.Bandwidth); err != nil {
+		report("bandwidth", "%v", err)
+	}
+	return problems
+}
+
+// CheckConnection connects to the cPanel instance and makes a harmless UAPI call,
+// reading the disk quota, to verify the credentials.
+func CheckConnection(config ConfigcPanel) (QuotaInfo, error) {
+	client, err := Connect(config)
+	if err != nil {
+		return QuotaInfo{}, err
+	}
+	quota, err := client.Quota.Get()
+	if err != nil {
+		return QuotaInfo{}, fmt.Errorf("cPanel rejected the API call, check username and password: %v", err)
+	}
+	return quota, nil
+}
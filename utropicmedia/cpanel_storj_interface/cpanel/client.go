@@ -0,0 +1,66 @@
+package cpanel
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default values used for unset HTTPOptions fields.
+const (
+	DefaultHTTPTimeout         = 60 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConnsPerHost = 4
+)
+
+// HTTPOptions tunes the HTTP client used to talk to the cPanel API.
+// Zero values fall back to the defaults above.
+type HTTPOptions struct {
+	// TimeoutSeconds limits a single API request, including reading the response.
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// IdleConnTimeoutSeconds is how long an idle keep-alive connection is kept open.
+	IdleConnTimeoutSeconds int `json:"idleConnTimeoutSeconds"`
+	// MaxIdleConnsPerHost is the number of idle keep-alive connections kept per cPanel host.
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+	// MaxConnsPerHost limits the number of connections per cPanel host, 0 means no limit.
+	MaxConnsPerHost int `json:"maxConnsPerHost"`
+	// DisableKeepAlives opens a new connection for every request.
+	DisableKeepAlives bool `json:"disableKeepAlives"`
+}
+
+// NewHTTPClient creates an HTTP client for the cPanel API.
+// The client keeps connections alive between requests, so repeated calls such as
+// backup status polling reuse the TLS session. It is safe to share one client
+// between several gateways, e.g. when backing up many accounts of a WHM server.
+func NewHTTPClient(options HTTPOptions, tlsConfig *tls.Config) *http.Client {
+	timeout := DefaultHTTPTimeout
+	if options.TimeoutSeconds > 0 {
+		timeout = time.Duration(options.TimeoutSeconds) * time.Second
+	}
+	idleConnTimeout := DefaultIdleConnTimeout
+	if options.IdleConnTimeoutSeconds > 0 {
+		idleConnTimeout = time.Duration(options.IdleConnTimeoutSeconds) * time.Second
+	}
+	maxIdleConnsPerHost := DefaultMaxIdleConnsPerHost
+	if options.MaxIdleConnsPerHost > 0 {
+		maxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			DisableKeepAlives:   options.DisableKeepAlives,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			MaxConnsPerHost:     options.MaxConnsPerHost,
+			IdleConnTimeout:     idleConnTimeout,
+		},
+	}
+}
@@ -1,28 +1,71 @@
 package cpanel
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
-)
 
-var i int = 0
-var insecure = true
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/ratelimit"
+)
 
 // Cpaneldata structure for backup file data
 type Cpaneldata struct {
 	FileName   string
 	FileHandle *os.File
+	// Time is when cPanel generated the backup.
+	Time time.Time
+	// Size is the size of the backup file in bytes.
+	Size int64
+	// Host and Account identify the cPanel account the backup belongs to.
+	Host    string
+	Account string
+	// Generated reports whether the backup was generated for this run,
+	// rather than an existing backup being selected.
+	Generated bool
+	// GenerationDuration is how long cPanel took to generate the backup.
+	GenerationDuration time.Duration
+
+	// limiter limits the rate at which the backup file is read to the cPanel bandwidth.
+	limiter *ratelimit.Limiter
+}
+
+// Close closes the backup file.
+func (data *Cpaneldata) Close() error {
+	return data.FileHandle.Close()
+}
+
+// Reader returns a reader of the backup file limited to the bandwidth of the cPanel configuration.
+// Like FileHandle it can be read at offsets and stat'ed, so that it can be uploaded in parts.
+func (data *Cpaneldata) Reader(ctx context.Context) io.Reader {
+	return limitedFile{
+		Reader:   data.limiter.Reader(ctx, data.FileHandle),
+		ReaderAt: data.limiter.ReaderAt(ctx, data.FileHandle),
+		file:     data.FileHandle,
+	}
+}
+
+// limitedFile reads a file through a limiter.
+type limitedFile struct {
+	io.Reader
+	io.ReaderAt
+	file *os.File
+}
+
+// Stat returns the FileInfo of the file.
+func (f limitedFile) Stat() (os.FileInfo, error) {
+	return f.file.Stat()
 }
 
 // ConfigcPanel defines the config variables and types for cPanel instance.
@@ -30,6 +73,54 @@ type ConfigcPanel struct {
 	HostName string `json:"hostname"`
 	UserName string `json:"username"`
 	Password string `json:"password"`
+
+	// CAFile is a PEM bundle used instead of the system roots to verify the cPanel certificate.
+	CAFile string `json:"caFile"`
+	// PinnedCertSHA256 is the SHA-256 fingerprint of a self-signed cPanel certificate to trust.
+	PinnedCertSHA256 string `json:"pinnedCertSHA256"`
+	// Insecure disables certificate verification altogether.
+	Insecure bool `json:"insecure"`
+
+	// HTTP tunes timeouts and connection reuse of the cPanel API client.
+	HTTP HTTPOptions `json:"http"`
+
+	// SkipSpaceCheck disables the disk space check done before a backup is generated.
+	SkipSpaceCheck bool `json:"skipSpaceCheck"`
+	// SpaceMarginPercent is added to the estimated backup size in the space check.
+	SpaceMarginPercent *int `json:"spaceMarginPercent"`
+	// BackupTimeoutMinutes limits how long to wait for cPanel to complete a backup.
+	BackupTimeoutMinutes int `json:"backupTimeoutMinutes"`
+
+	// Bandwidth limits the rate at which backups are read, from the backup file in the home directory
+	// as well as through cPanel's download endpoint.
+	Bandwidth ratelimit.Config `json:"bandwidth"`
+}
+
+// DefaultBackupTimeout is used when BackupTimeoutMinutes is not set.
+const DefaultBackupTimeout = 4 * time.Hour
+
+// backupPollInterval is the delay between two backup status checks.
+const backupPollInterval = 10 * time.Second
+
+// HomeDir returns the home directory of the cPanel account, where full backups are written.
+func (config ConfigcPanel) HomeDir() string {
+	return "/home/" + config.UserName
+}
+
+// spaceMarginPercent returns the configured space margin or its default.
+func (config ConfigcPanel) spaceMarginPercent() int {
+	if config.SpaceMarginPercent == nil {
+		return DefaultSpaceMarginPercent
+	}
+	return *config.SpaceMarginPercent
+}
+
+// backupTimeout returns the configured backup timeout or its default.
+func (config ConfigcPanel) backupTimeout() time.Duration {
+	if config.BackupTimeoutMinutes <= 0 {
+		return DefaultBackupTimeout
+	}
+	return time.Duration(config.BackupTimeoutMinutes) * time.Minute
 }
 
 var ResponseSizeLimit = (20 * 1024 * 1024) + 1337
@@ -59,19 +150,8 @@ func (c *JSONAPIGateway) api(req CpanelAPIRequest, out interface{}) error {
 
 	httpReq.SetBasicAuth(c.Username, c.Password)
 
-	if c.cl == nil {
-		c.cl = &http.Client{}
-		c.cl.Transport = &http.Transport{
-			DisableKeepAlives:   true,
-			MaxIdleConns:        1,
-			MaxIdleConnsPerHost: 1,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: c.Insecure,
-			},
-		}
-	}
-
-	resp, err := c.cl.Do(httpReq)
+	started := time.Now()
+	resp, err := c.client().Do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -89,16 +169,20 @@ func (c *JSONAPIGateway) api(req CpanelAPIRequest, out interface{}) error {
 		return err
 	}
 
-	if os.Getenv("DEBUG_CPANEL_RESPONSES") == "1" {
-		log.Println(reqURL)
-		log.Println(resp.Status)
-		log.Println(req.Function)
-		log.Println(req.Arguments)
-		log.Println(vals)
-		log.Println(string(bytes))
+	if log := c.log(); log.Enabled(logging.Debug) {
+		log.Debug("cPanel API response",
+			logging.F("url", logging.RedactURL(reqURL)),
+			logging.F("status", resp.Status),
+			logging.F("module", req.Module),
+			logging.F("function", req.Function),
+			logging.F("arguments", logging.RedactValues(vals).Encode()),
+			logging.F("duration", time.Since(started)),
+			logging.F("body", logging.RedactJSON(bytes)))
 	}
 
 	if len(bytes) == ResponseSizeLimit {
+		// drain the rest so the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
 		return errors.New("API response maximum size exceeded")
 	}
 
@@ -112,7 +196,8 @@ func (r BaseResult) Error() error {
 	return errors.New(r.ErrorString)
 }
 
-// UAPI function creates a UAPI client for cPanel
+// UAPI function creates a UAPI client for cPanel.
+// A response with status 0 is returned as an *APIError.
 func (c *JSONAPIGateway) UAPI(module, function string, arguments Args, out interface{}) error {
 	req := CpanelAPIRequest{
 		APIVersion: "uapi",
@@ -121,10 +206,19 @@ func (c *JSONAPIGateway) UAPI(module, function string, arguments Args, out inter
 		Arguments:  arguments,
 	}
 
-	return c.api(req, out)
+	var raw json.RawMessage
+	if err := c.api(req, &raw); err != nil {
+		return err
+	}
+	if err := checkUAPIResult(module, function, raw); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, out)
 }
 
-// API2 function creates API2 client
+// API2 function creates API2 client.
+// A response with an error or a failed event result is returned as an *APIError.
 func (c *JSONAPIGateway) API2(module, function string, arguments Args, out interface{}) error {
 	req := CpanelAPIRequest{
 		APIVersion: "2",
@@ -135,8 +229,16 @@ func (c *JSONAPIGateway) API2(module, function string, arguments Args, out inter
 
 	var result API2Result
 	err := c.api(req, &result)
+	if err == nil && result.ErrorString != "" {
+		err = &APIError{
+			APIVersion: "2",
+			Module:     module,
+			Function:   function,
+			Errors:     []string{result.ErrorString},
+		}
+	}
 	if err == nil {
-		err = result.Error()
+		err = checkAPI2Result(module, function, result.Result)
 	}
 	if err != nil {
 		return err
@@ -145,13 +247,95 @@ func (c *JSONAPIGateway) API2(module, function string, arguments Args, out inter
 	return json.Unmarshal(result.Result, out)
 }
 
+// Download fetches a file from the account's home directory through cPanel's download endpoint.
+// The caller must close the returned reader.
+func (c *JSONAPIGateway) Download(path string) (io.ReadCloser, error) {
+	vals := url.Values{}
+	vals.Add("skipencode", "1")
+	vals.Add("file", path)
+	reqURL := fmt.Sprintf("https://%s:2083/download?%s", c.Hostname, vals.Encode())
+
+	httpReq, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.SetBasicAuth(c.Username, c.Password)
+
+	// Downloads of full backups take far longer than the API timeout of the client,
+	// so they use the same transport without the overall request timeout.
+	cl := *c.client()
+	cl.Timeout = 0
+
+	resp, err := cl.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+	return c.Limiter.ReadCloser(context.Background(), resp.Body), nil
+}
+
 // JSONAPIGateway defines the properties of the client
 type JSONAPIGateway struct {
-	Hostname string
-	Username string
-	Password string
-	Insecure bool
-	cl       *http.Client
+	Hostname  string
+	Username  string
+	Password  string
+	Insecure  bool
+	TLSConfig *tls.Config
+	// Client is used for all requests of the gateway. It may be shared between gateways
+	// or replaced, e.g. in tests. When nil, a client is created from NewHTTPClient.
+	Client *http.Client
+	// Limiter limits the rate of file downloads, nil means unlimited.
+	Limiter *ratelimit.Limiter
+	// Log receives the debug tracing of API calls. When nil, the package logger is used.
+	Log *logging.Logger
+
+	once sync.Once
+}
+
+// log returns the logger of the gateway.
+func (c *JSONAPIGateway) log() *logging.Logger {
+	if c.Log != nil {
+		return c.Log
+	}
+	return logger
+}
+
+// client returns the HTTP client of the gateway, creating the default one on first use.
+func (c *JSONAPIGateway) client() *http.Client {
+	c.once.Do(func() {
+		if c.Client == nil {
+			c.Client = NewHTTPClient(HTTPOptions{}, c.tlsConfig())
+		}
+	})
+	return c.Client
+}
+
+// tlsConfig returns the TLS configuration of the gateway,
+// falling back to the Insecure flag when none was given.
+func (c *JSONAPIGateway) tlsConfig() *tls.Config {
+	if c.TLSConfig != nil {
+		return c.TLSConfig
+	}
+	return &tls.Config{
+		InsecureSkipVerify: c.Insecure,
+	}
+}
+
+// verifiesCertificate reports whether the gateway verifies the cPanel certificate, against the
+// trusted roots or a pinned fingerprint. A client with a transport of its own is trusted to do so.
+func (c *JSONAPIGateway) verifiesCertificate() bool {
+	tlsConfig := c.tlsConfig()
+	if c.Client != nil {
+		transport, ok := c.Client.Transport.(*http.Transport)
+		if !ok {
+			return true
+		}
+		tlsConfig = transport.TLSClientConfig
+	}
+	return tlsConfig == nil || !tlsConfig.InsecureSkipVerify || tlsConfig.VerifyPeerCertificate != nil
 }
 
 // APIGateway consitutes the client of UAPI and API1
@@ -168,6 +352,26 @@ type Api struct {
 // CpanelAPI is used to access the Cpanel features
 type CpanelAPI struct {
 	Api
+
+	Backup  BackupAPI
+	Fileman FilemanAPI
+	Mysql   MysqlAPI
+	Quota   QuotaAPI
+}
+
+// newCpanelAPI wires the typed function wrappers to the gateway.
+// Every constructor goes through it, so it warns about gateways that do not verify the cPanel certificate.
+func newCpanelAPI(gw APIGateway) CpanelAPI {
+	if c, ok := gw.(*JSONAPIGateway); ok && !c.verifiesCertificate() {
+		c.log().Warn("TLS certificate verification is disabled, set caFile or pinnedCertSHA256 instead of insecure to verify the cPanel certificate")
+	}
+	return CpanelAPI{
+		Api:     NewAPI(gw),
+		Backup:  BackupAPI{gw},
+		Fileman: FilemanAPI{gw},
+		Mysql:   MysqlAPI{gw},
+		Quota:   QuotaAPI{gw},
+	}
 }
 
 // CpanelAPIRequest consists all information of function to be used
@@ -220,7 +424,31 @@ func NewJSONAPI(hostname, username, password string, insecure bool) (CpanelAPI,
 		Insecure: insecure,
 	}
 	// todo: a way to check the username/password here
-	return CpanelAPI{NewAPI(c)}, nil
+	return newCpanelAPI(c), nil
+}
+
+// NewJSONAPIWithTLS returns the client to be used for accessing cPanel features,
+// verifying the cPanel certificate with the given TLS configuration
+func NewJSONAPIWithTLS(hostname, username, password string, tlsConfig *tls.Config) (CpanelAPI, error) {
+	c := &JSONAPIGateway{
+		Hostname:  hostname,
+		Username:  username,
+		Password:  password,
+		TLSConfig: tlsConfig,
+	}
+	return newCpanelAPI(c), nil
+}
+
+// NewJSONAPIWithClient returns the client to be used for accessing cPanel features,
+// sending all requests through the given HTTP client
+func NewJSONAPIWithClient(hostname, username, password string, client *http.Client) (CpanelAPI, error) {
+	c := &JSONAPIGateway{
+		Hostname: hostname,
+		Username: username,
+		Password: password,
+		Client:   client,
+	}
+	return newCpanelAPI(c), nil
 }
 
 type API2Result struct {
@@ -270,6 +498,7 @@ type ListfullbackupsApiResponse struct {
 // LoadcPanelProperty reads and parses the JSON file.
 // that contains a cPanel instance's property.
 // and returns all the properties as an object.
+// Unknown fields and invalid values are not reported; config.LoadCPanel checks the file strictly.
 func LoadcPanelProperty(fullFileName string) (ConfigcPanel, error) { // fullFileName for fetching cPanel credentials from given JSON filename.
 	var configcPanel ConfigcPanel
 
@@ -281,90 +510,328 @@ func LoadcPanelProperty(fullFileName string) (ConfigcPanel, error) { // fullFile
 	defer fileHandle.Close()
 
 	jsonParser := json.NewDecoder(fileHandle)
-	jsonParser.Decode(&configcPanel)
-
-	// Display read information.
-	fmt.Println("\nReading cPanel configuration from file: ", fullFileName)
+	if err := jsonParser.Decode(&configcPanel); err != nil {
+		return configcPanel, fmt.Errorf("could not parse %s: %v", fullFileName, err)
+	}
 
 	// Display read information.
-	fmt.Println("\nReading cPanel configuration from file: ", fullFileName)
-	fmt.Println("Host Name\t: ", configcPanel.HostName)
-	fmt.Println("User Name\t: ", configcPanel.UserName)
-	fmt.Println("Password\t: ", configcPanel.Password)
+	logger.Info("Read cPanel configuration",
+		logging.F("file", fullFileName),
+		logging.F("host", configcPanel.HostName),
+		logging.F("account", configcPanel.UserName),
+		logging.F("password", logging.Redact(configcPanel.Password)))
 	return configcPanel, nil
 }
 
+// BackupOptions selects the backup ConnectToCpanel returns.
+// With the zero value a new full backup is always generated.
+type BackupOptions struct {
+	// Reuse selects the newest complete backup in the home directory instead of generating one.
+	Reuse bool
+	// MaxAge limits Reuse to backups younger than MaxAge.
+	// A new backup is generated when there is none.
+	MaxAge time.Duration
+	// FileName selects an existing complete backup by its file name.
+	FileName string
+	// Bandwidth overrides the bandwidth limit of the cPanel configuration when set.
+	Bandwidth *ratelimit.Config
+}
+
+// Connect creates an API client for the configured cPanel instance
+// and checks that the instance is reachable.
+func Connect(configcPanel ConfigcPanel) (CpanelAPI, error) {
+	tlsConfig, err := configcPanel.TLSConfig()
+	if err != nil {
+		return CpanelAPI{}, err
+	}
+	limiter, err := ratelimit.New(configcPanel.Bandwidth)
+	if err != nil {
+		return CpanelAPI{}, fmt.Errorf("bandwidth: %v", err)
+	}
+	log := accountLogger(configcPanel)
+
+	// Create connection with cPanel
+	log.Info("Connecting to cPanel")
+	client := newCpanelAPI(&JSONAPIGateway{
+		Hostname: configcPanel.HostName,
+		Username: configcPanel.UserName,
+		Password: configcPanel.Password,
+		Client:   NewHTTPClient(configcPanel.HTTP, tlsConfig),
+		Limiter:  limiter,
+		Log:      log,
+	})
+
+	timeout := time.Duration(1 * time.Second)
+	conn, err := net.DialTimeout("tcp", configcPanel.HostName+":2083", timeout)
+	if err != nil {
+		return CpanelAPI{}, err
+	}
+	conn.Close()
+	log.Info("Connected to cPanel")
+
+	return client, nil
+}
+
+// GenerateBackup creates a new full backup in the account's home directory
+// and waits until cPanel has completed it.
+func GenerateBackup(client CpanelAPI, configcPanel ConfigcPanel) (FullBackup, error) {
+	return GenerateBackupContext(context.Background(), client, configcPanel)
+}
+
+// GenerateBackupContext is like GenerateBackup, but stops waiting for the backup when ctx is done.
+// cPanel completes a backup it has started anyway.
+func GenerateBackupContext(ctx context.Context, client CpanelAPI, configcPanel ConfigcPanel) (FullBackup, error) {
+	backups, err := client.Backup.List()
+	if err != nil {
+		return FullBackup{}, err
+	}
+	prevLen := len(backups)
+	log := accountLogger(configcPanel)
+
+	if configcPanel.SkipSpaceCheck {
+		log.Info("Skipping disk space check")
+	} else {
+		log.Info("Checking disk space")
+		check, err := CheckBackupSpace(client, configcPanel.HomeDir(), backups, configcPanel.spaceMarginPercent())
+		if err != nil {
+			return FullBackup{}, err
+		}
+		if check.AvailableBytes < 0 {
+			log.Info("Estimated backup size, no space limit", logging.F("estimatedMB", check.EstimatedBytes/megabyte))
+		} else {
+			log.Info("Estimated backup size",
+				logging.F("estimatedMB", check.EstimatedBytes/megabyte),
+				logging.F("availableMB", check.AvailableBytes/megabyte))
+		}
+	}
+
+	// Creates a full backup to the user's home directory
+	log.Info("Creating full backup")
+	started := time.Now()
+	_, err = client.Backup.FullBackupToHomedir("")
+	if err != nil {
+		return FullBackup{}, fmt.Errorf("full backup error: %v", err)
+	}
+
+	backup, err := waitForBackup(ctx, client, prevLen, configcPanel.backupTimeout())
+	if err != nil {
+		return FullBackup{}, err
+	}
+
+	log.Info("Completed full backup", logging.F("file", backup.File), logging.F("duration", time.Since(started)))
+	return backup, nil
+}
+
+// SelectBackup picks an existing complete backup according to options.
+// It reports false when a new backup has to be generated instead.
+func SelectBackup(backups []FullBackup, options BackupOptions) (FullBackup, bool, error) {
+	if options.FileName != "" {
+		for _, backup := range backups {
+			if backup.File != options.FileName {
+				continue
+			}
+			if !backup.Complete() {
+				return FullBackup{}, false, fmt.Errorf("backup %s is not complete (status %q)", backup.File, backup.Status)
+			}
+			return backup, true, nil
+		}
+		return FullBackup{}, false, fmt.Errorf("backup %s not found in the home directory", options.FileName)
+	}
+
+	if !options.Reuse {
+		return FullBackup{}, false, nil
+	}
+
+	var newest FullBackup
+	var found bool
+	for _, backup := range backups {
+		if backup.Complete() && (!found || backup.Time.After(newest.Time)) {
+			newest, found = backup, true
+		}
+	}
+	if !found {
+		return FullBackup{}, false, nil
+	}
+	if options.MaxAge > 0 && time.Since(newest.Time) > options.MaxAge {
+		return FullBackup{}, false, nil
+	}
+	return newest, true, nil
+}
+
+// CompleteBackups returns the complete backups among backups.
+func CompleteBackups(backups []FullBackup) []FullBackup {
+	var complete []FullBackup
+	for _, backup := range backups {
+		if backup.Complete() {
+			complete = append(complete, backup)
+		}
+	}
+	return complete
+}
+
+// OpenBackup opens a backup file in the account's home directory for reading.
+// Cpaneldata.Reader reads it at the rate allowed by the bandwidth of configcPanel.
+func OpenBackup(configcPanel ConfigcPanel, backup FullBackup) (*Cpaneldata, error) {
+	limiter, err := ratelimit.New(configcPanel.Bandwidth)
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth: %v", err)
+	}
+
+	// Created file handle for backup file
+	file, err := os.Open(configcPanel.HomeDir() + "/" + backup.File)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Cpaneldata{
+		FileHandle: file,
+		FileName:   backup.File,
+		Time:       backup.Time,
+		Size:       info.Size(),
+		Host:       configcPanel.HostName,
+		Account:    configcPanel.UserName,
+		limiter:    limiter,
+	}, nil
+}
+
 // ConnectToCpanel will connect to a cPanel instance,
 // based on the read property from an external file.
-// It returns a reference to an io.Reader with cPanel instance information.
-func ConnectToCpanel(fullFileName string) (*Cpaneldata, error) {
+// It either generates a new full backup or selects an existing one according to options,
+// and returns a reference to an io.Reader with cPanel instance information.
+func ConnectToCpanel(fullFileName string, options BackupOptions) (*Cpaneldata, error) {
 
 	// Read cPanel instance's properties from an external file.
 	configcPanel, err := LoadcPanelProperty(fullFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return ConnectToCpanelWithConfig(configcPanel, options)
+}
+
+// ConnectToCpanelWithConfig is like ConnectToCpanel for an already loaded configuration,
+// e.g. the cPanel source of a profile.
+func ConnectToCpanelWithConfig(configcPanel ConfigcPanel, options BackupOptions) (*Cpaneldata, error) {
+	return ConnectToCpanelWithContext(context.Background(), configcPanel, options)
+}
+
+// ConnectToCpanelWithContext is like ConnectToCpanelWithConfig, but stops waiting for
+// a backup being generated when ctx is done.
+func ConnectToCpanelWithContext(ctx context.Context, configcPanel ConfigcPanel, options BackupOptions) (*Cpaneldata, error) {
+	if options.Bandwidth != nil {
+		configcPanel.Bandwidth = *options.Bandwidth
+	}
 
+	client, err := Connect(configcPanel)
 	if err != nil {
-		log.Fatal("Load cPanel Property:", err)
+		return nil, err
 	}
 
-	// Create connection with cPanel
-	fmt.Println("\nConnecting to cPanel...")
-	client, err := NewJSONAPI(configcPanel.HostName, configcPanel.UserName, configcPanel.Password, insecure)
+	backups, err := client.Backup.List()
 	if err != nil {
 		return nil, err
 	}
 
-	timeout := time.Duration(1 * time.Second)
-	_, err = net.DialTimeout("tcp", configcPanel.HostName+":2083", timeout)
+	backup, found, err := SelectBackup(backups, options)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Successfully connected to cPanel!")
+	if found {
+		accountLogger(configcPanel).Info("Using existing full backup", logging.F("file", backup.File), logging.F("created", backup.Time))
+	} else {
+		if options.Reuse {
+			accountLogger(configcPanel).Info("No existing backup to reuse")
+		}
+		started := time.Now()
+		backup, err = GenerateBackupContext(ctx, client, configcPanel)
+		if err != nil {
+			return nil, err
+		}
+		data, err := OpenBackup(configcPanel, backup)
+		if err != nil {
+			return nil, err
+		}
+		data.Generated = true
+		data.GenerationDuration = time.Since(started)
+		return data, nil
+	}
 
-	var list ListfullbackupsApiResponse
-	err = client.Gateway.API2("Backups", "listfullbackups", Args{}, &list)
-	prevLen := len(list.Data)
+	return OpenBackup(configcPanel, backup)
+}
 
-	// Creates a full backup to the user's home directory
-	fmt.Println("Creating Full Backup...")
-	var out FullBackuptoHomeDirAPIResponse
-	err = client.Gateway.UAPI("Backup", "fullbackup_to_homedir", Args{
-		"email": "",
-	}, &out)
+// LocalBackups connects to a cPanel instance, based on the read property from an external file,
+// and returns its properties together with the complete backups in the account's home directory.
+func LocalBackups(fullFileName string) (ConfigcPanel, []FullBackup, error) {
+	configcPanel, err := LoadcPanelProperty(fullFileName)
+	if err != nil {
+		return configcPanel, nil, err
+	}
 
+	backups, err := LocalBackupsWithConfig(configcPanel)
+	return configcPanel, backups, err
+}
+
+// LocalBackupsWithConfig is like LocalBackups for an already loaded configuration.
+func LocalBackupsWithConfig(configcPanel ConfigcPanel) ([]FullBackup, error) {
+	client, err := Connect(configcPanel)
 	if err != nil {
-		log.Fatal("Full Backup Error : ", err)
+		return nil, err
 	}
 
-	time.Sleep(10 * time.Second) //Wait for backup file to be created
+	backups, err := client.Backup.List()
+	if err != nil {
+		return nil, err
+	}
+	return CompleteBackups(backups), nil
+}
 
-	var status string //status of the backup file "inprogress or complete"
-	var fileName string
+// waitForBackup polls the account's backups until the one started after prevLen
+// backups existed is complete, and returns it.
+// It fails when cPanel reports the backup as failed, when the account runs out of quota
+// while the backup is written, when the backup does not complete within timeout, or when ctx is done.
+func waitForBackup(ctx context.Context, client CpanelAPI, prevLen int, timeout time.Duration) (FullBackup, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		//Wait for backup file to be created
+		select {
+		case <-time.After(backupPollInterval):
+		case <-ctx.Done():
+			return FullBackup{}, ctx.Err()
+		}
 
-	for status != "complete" {
 		// Lists the account's backup files.
-		var list ListfullbackupsApiResponse
-		err = client.Gateway.API2("Backups", "listfullbackups", Args{}, &list)
+		backups, err := client.Backup.List()
 		if err != nil {
-			log.Fatal(err)
+			return FullBackup{}, err
 		}
 
-		currLen := len(list.Data)
-		if currLen > prevLen {
-			status = list.Data[currLen-1].Status
-			fileName = list.Data[currLen-1].File
+		if len(backups) > prevLen {
+			backup := backups[len(backups)-1]
+			switch backup.Status {
+			case BackupStatusComplete:
+				return backup, nil
+			case BackupStatusInProgress:
+			default:
+				return FullBackup{}, fmt.Errorf("backup %s failed with status %q: %s", backup.File, backup.Status, backup.Reason)
+			}
 		}
 
-	}
-
-	fmt.Printf("Completed Full Backup:\t%s", fileName)
-
-	// Created file handle for backup file
-	file, err := os.Open("/home/" + configcPanel.UserName + "/" + fileName)
+		quota, err := client.Quota.Get()
+		if err != nil {
+			return FullBackup{}, err
+		}
+		if !quota.Unlimited() && quota.MegabytesRemain <= 0 {
+			return FullBackup{}, errors.New("account ran out of disk quota while the backup was written")
+		}
 
-	if err != nil {
-		log.Fatal(err)
+		if time.Now().After(deadline) {
+			return FullBackup{}, fmt.Errorf("backup did not complete within %s", timeout)
+		}
 	}
-
-	return &Cpaneldata{FileHandle: file, FileName: fileName}, nil
-
 }
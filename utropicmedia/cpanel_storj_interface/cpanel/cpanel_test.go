@@ -0,0 +1,41 @@
+package cpanel
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVerifiesCertificate(t *testing.T) {
+	pinned, err := ConfigcPanel{
+		HostName:         "cpanel.example.com",
+		PinnedCertSHA256: strings.Repeat("AB:", 31) + "AB",
+	}.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	insecure := &tls.Config{InsecureSkipVerify: true}
+
+	tests := []struct {
+		name     string
+		gateway  *JSONAPIGateway
+		verifies bool
+	}{
+		{"default", &JSONAPIGateway{}, true},
+		{"insecure", &JSONAPIGateway{Insecure: true}, false},
+		{"TLS configuration", &JSONAPIGateway{TLSConfig: &tls.Config{}}, true},
+		{"insecure TLS configuration", &JSONAPIGateway{TLSConfig: insecure}, false},
+		{"pinned certificate", &JSONAPIGateway{TLSConfig: pinned}, true},
+		{"client", &JSONAPIGateway{Client: NewHTTPClient(HTTPOptions{}, &tls.Config{})}, true},
+		{"insecure client", &JSONAPIGateway{Client: NewHTTPClient(HTTPOptions{}, insecure)}, false},
+		{"client with the default transport", &JSONAPIGateway{Client: &http.Client{}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if verifies := test.gateway.verifiesCertificate(); verifies != test.verifies {
+				t.Errorf("verifiesCertificate() = %v, want %v", verifies, test.verifies)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package cpanel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// APIError is returned by the gateway when cPanel reports that a call failed,
+// either through the UAPI status field or through the API2 error and event result.
+type APIError struct {
+	APIVersion string
+	Module     string
+	Function   string
+	// Status is the UAPI status or the API2 event result, 0 on failure.
+	Status int
+	// Errors holds the reasons cPanel gave for the failure.
+	Errors []string
+	// Messages holds informational messages returned along with the failure.
+	Messages []string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	reasons := e.Errors
+	if len(reasons) == 0 {
+		reasons = []string{"call failed"}
+	}
+	return fmt.Sprintf("%s::%s (status %d): %s", e.Module, e.Function, e.Status, strings.Join(reasons, "; "))
+}
+
+// uapiEnvelope holds the fields common to every UAPI response.
+type uapiEnvelope struct {
+	Status   *int     `json:"status"`
+	Errors   []string `json:"errors"`
+	Messages []string `json:"messages"`
+}
+
+// api2Envelope holds the fields common to every API2 response.
+type api2Envelope struct {
+	ErrorString string `json:"error"`
+	Event       *struct {
+		Result int    `json:"result"`
+		Reason string `json:"reason"`
+	} `json:"event"`
+}
+
+// checkUAPIResult inspects the envelope of a UAPI response and returns an *APIError if the call failed.
+func checkUAPIResult(module, function string, data []byte) error {
+	var envelope uapiEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	if envelope.Status == nil || *envelope.Status != 0 {
+		return nil
+	}
+	return &APIError{
+		APIVersion: "uapi",
+		Module:     module,
+		Function:   function,
+		Status:     *envelope.Status,
+		Errors:     envelope.Errors,
+		Messages:   envelope.Messages,
+	}
+}
+
+// checkAPI2Result inspects the cpanelresult of an API2 response and returns an *APIError if the call failed.
+func checkAPI2Result(module, function string, data []byte) error {
+	var envelope api2Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+
+	apiErr := &APIError{
+		APIVersion: "2",
+		Module:     module,
+		Function:   function,
+		Status:     1,
+	}
+	if envelope.Event != nil {
+		apiErr.Status = envelope.Event.Result
+		if envelope.Event.Reason != "" {
+			apiErr.Errors = append(apiErr.Errors, envelope.Event.Reason)
+		}
+	}
+	if envelope.ErrorString != "" {
+		apiErr.Status = 0
+		apiErr.Errors = append([]string{envelope.ErrorString}, apiErr.Errors...)
+	}
+	if apiErr.Status != 0 {
+		return nil
+	}
+	return apiErr
+}
@@ -0,0 +1,108 @@
+package cpanel
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// FilemanAPI wraps the cPanel Fileman module.
+type FilemanAPI struct {
+	gw APIGateway
+}
+
+// Downloader is implemented by gateways that can fetch raw files from the account.
+type Downloader interface {
+	Download(path string) (io.ReadCloser, error)
+}
+
+// File describes an entry of a directory listing.
+type File struct {
+	Name     string
+	FullPath string
+	Type     string
+	Size     int64
+	ModTime  time.Time
+}
+
+// IsDir reports whether the entry is a directory.
+func (f File) IsDir() bool {
+	return f.Type == "dir"
+}
+
+type listFilesAPIResponse struct {
+	BaseUAPIResponse
+	Data []struct {
+		File     string `json:"file"`
+		FullPath string `json:"fullpath"`
+		Type     string `json:"type"`
+		Size     Number `json:"size"`
+		Mtime    Number `json:"mtime"`
+	} `json:"data"`
+}
+
+type fileopAPIResponse struct {
+	BaseAPI2Response
+	Data []struct {
+		Result Number `json:"result"`
+		Reason string `json:"reason"`
+	} `json:"data"`
+}
+
+// List returns the entries of a directory of the account, including hidden files.
+func (f FilemanAPI) List(dir string) ([]File, error) {
+	var out listFilesAPIResponse
+	err := f.gw.UAPI("Fileman", "list_files", Args{
+		"dir":         dir,
+		"show_hidden": 1,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(out.Data))
+	for _, entry := range out.Data {
+		files = append(files, File{
+			Name:     entry.File,
+			FullPath: entry.FullPath,
+			Type:     entry.Type,
+			Size:     entry.Size.Int64(),
+			ModTime:  time.Unix(entry.Mtime.Int64(), 0),
+		})
+	}
+	return files, nil
+}
+
+// Download streams a file of the account. The caller must close the returned reader.
+func (f FilemanAPI) Download(path string) (io.ReadCloser, error) {
+	downloader, ok := f.gw.(Downloader)
+	if !ok {
+		return nil, errors.New("Fileman::download: gateway does not support downloads")
+	}
+	return downloader.Download(path)
+}
+
+// Delete permanently removes the given files of the account.
+func (f FilemanAPI) Delete(paths ...string) error {
+	var out fileopAPIResponse
+	err := f.gw.API2("Fileman", "fileop", Args{
+		"op":           "unlink",
+		"sourcefiles":  strings.Join(paths, ","),
+		"doubledecode": 0,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	for _, entry := range out.Data {
+		if entry.Result != 1 {
+			return &APIError{
+				APIVersion: "2",
+				Module:     "Fileman",
+				Function:   "fileop",
+				Errors:     []string{entry.Reason},
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package cpanel
+
+import (
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// logger receives the progress messages and the debug tracing of the package.
+var logger = logging.Default()
+
+// SetLogger replaces the logger of the package, e.g. to add fields identifying the job
+// or to write JSON.
+func SetLogger(l *logging.Logger) {
+	logger = l
+}
+
+// accountLogger returns the package logger with the fields identifying the cPanel account.
+func accountLogger(configcPanel ConfigcPanel) *logging.Logger {
+	return logger.With(logging.F("host", configcPanel.HostName), logging.F("account", configcPanel.UserName))
+}
@@ -0,0 +1,41 @@
+package cpanel
+
+// MysqlAPI wraps the cPanel Mysql module.
+type MysqlAPI struct {
+	gw APIGateway
+}
+
+// Database describes a MySQL database of the account.
+type Database struct {
+	Name      string
+	DiskUsage int64
+	Users     []string
+}
+
+type listDatabasesAPIResponse struct {
+	BaseUAPIResponse
+	Data []struct {
+		Database  string   `json:"database"`
+		DiskUsage Number   `json:"disk_usage"`
+		Users     []string `json:"users"`
+	} `json:"data"`
+}
+
+// ListDatabases returns the MySQL databases of the account.
+func (m MysqlAPI) ListDatabases() ([]Database, error) {
+	var out listDatabasesAPIResponse
+	err := m.gw.UAPI("Mysql", "list_databases", Args{}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	databases := make([]Database, 0, len(out.Data))
+	for _, entry := range out.Data {
+		databases = append(databases, Database{
+			Name:      entry.Database,
+			DiskUsage: entry.DiskUsage.Int64(),
+			Users:     entry.Users,
+		})
+	}
+	return databases, nil
+}
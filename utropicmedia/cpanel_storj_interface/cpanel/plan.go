@@ -0,0 +1,75 @@
+package cpanel
+
+import (
+	"os"
+	"time"
+)
+
+// BackupPlan describes the backup ConnectToCpanel would return, found without generating a backup.
+type BackupPlan struct {
+	// Backup is the existing backup that would be selected, or the expected name and time
+	// of the backup that would be generated.
+	Backup FullBackup
+	// Generate reports whether a new backup would be generated.
+	Generate bool
+	// Size is the size of the selected backup file, or the estimated size of a new backup, 0 when unknown.
+	Size int64
+	// Space is the disk space check for a new backup, nil when it is not needed or skipped.
+	Space *SpaceCheck
+}
+
+// BackupFileName returns the name cPanel gives a full backup of account started at t.
+func BackupFileName(account string, t time.Time) string {
+	return "backup-" + t.Format("1.2.2006_15-04-05") + "_" + account + ".tar.gz"
+}
+
+// BackupSize returns the size of the file of a backup in the account's home directory.
+func BackupSize(configcPanel ConfigcPanel, backup FullBackup) (int64, error) {
+	info, err := os.Stat(configcPanel.HomeDir() + "/" + backup.File)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// PlanBackup connects to the cPanel instance and returns which backup ConnectToCpanelWithConfig
+// would upload with options, without generating one. Like GenerateBackup, it fails when a new
+// backup is not expected to fit into the home directory.
+func PlanBackup(configcPanel ConfigcPanel, options BackupOptions) (BackupPlan, error) {
+	client, err := Connect(configcPanel)
+	if err != nil {
+		return BackupPlan{}, err
+	}
+
+	backups, err := client.Backup.List()
+	if err != nil {
+		return BackupPlan{}, err
+	}
+
+	backup, found, err := SelectBackup(backups, options)
+	if err != nil {
+		return BackupPlan{}, err
+	}
+	if found {
+		size, err := BackupSize(configcPanel, backup)
+		if err != nil {
+			return BackupPlan{}, err
+		}
+		return BackupPlan{Backup: backup, Size: size}, nil
+	}
+
+	now := time.Now()
+	plan := BackupPlan{
+		Backup:   FullBackup{File: BackupFileName(configcPanel.UserName, now), Time: now},
+		Generate: true,
+	}
+	if configcPanel.SkipSpaceCheck {
+		return plan, nil
+	}
+	check, err := CheckBackupSpace(client, configcPanel.HomeDir(), backups, configcPanel.spaceMarginPercent())
+	if err != nil {
+		return BackupPlan{}, err
+	}
+	plan.Size, plan.Space = check.EstimatedBytes, &check
+	return plan, nil
+}
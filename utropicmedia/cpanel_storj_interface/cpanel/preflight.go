@@ -0,0 +1,113 @@
+package cpanel
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultSpaceMarginPercent is added on top of the estimated backup size.
+const DefaultSpaceMarginPercent = 10
+
+// estimateFromBackups is the number of previous backups the size estimate is based on.
+const estimateFromBackups = 3
+
+const megabyte = 1024 * 1024
+
+// SpaceCheck is the result of the pre-flight disk space check.
+type SpaceCheck struct {
+	// EstimatedBytes is the expected size of the new backup, including the margin.
+	EstimatedBytes int64
+	// EstimateSource describes what the estimate is based on.
+	EstimateSource string
+	// AvailableBytes is the space left for the backup, -1 when there is no known limit.
+	AvailableBytes int64
+	// AvailableSource describes which limit AvailableBytes comes from.
+	AvailableSource string
+}
+
+// Sufficient reports whether the estimated backup fits into the available space.
+func (s SpaceCheck) Sufficient() bool {
+	return s.AvailableBytes < 0 || s.EstimatedBytes <= s.AvailableBytes
+}
+
+// InsufficientSpaceError is returned when a full backup is not expected to fit into the home directory.
+type InsufficientSpaceError struct {
+	SpaceCheck
+}
+
+// Error implements the error interface.
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space for a full backup: about %d MB needed (%s), %d MB available (%s)",
+		e.EstimatedBytes/megabyte, e.EstimateSource, e.AvailableBytes/megabyte, e.AvailableSource)
+}
+
+// CheckBackupSpace estimates the size of a new full backup and compares it with
+// the account's remaining quota and the free space of the file system holding homeDir.
+// The estimate is the largest of the last few complete backups found in homeDir,
+// or the account's disk usage when there are none, plus marginPercent.
+// It returns an *InsufficientSpaceError when the backup is not expected to fit.
+func CheckBackupSpace(client CpanelAPI, homeDir string, backups []FullBackup, marginPercent int) (SpaceCheck, error) {
+	var check SpaceCheck
+
+	quota, err := client.Quota.Get()
+	if err != nil {
+		return check, err
+	}
+
+	sizes, err := backupSizes(client, homeDir, backups)
+	if err != nil {
+		return check, err
+	}
+
+	var estimate int64
+	if len(sizes) > 0 {
+		for _, size := range sizes {
+			if size > estimate {
+				estimate = size
+			}
+		}
+		check.EstimateSource = fmt.Sprintf("largest of the last %d backups", len(sizes))
+	} else {
+		estimate = int64(quota.MegabytesUsed * megabyte)
+		check.EstimateSource = "account disk usage, no previous backups"
+	}
+	check.EstimatedBytes = int64(math.Ceil(float64(estimate) * (1 + float64(marginPercent)/100)))
+
+	check.AvailableBytes = -1
+	if !quota.Unlimited() {
+		check.AvailableBytes = int64(quota.MegabytesRemain * megabyte)
+		check.AvailableSource = "account quota"
+	}
+	if free, ok := freeSpace(homeDir); ok && (check.AvailableBytes < 0 || free < check.AvailableBytes) {
+		check.AvailableBytes = free
+		check.AvailableSource = "free disk space"
+	}
+
+	if !check.Sufficient() {
+		return check, &InsufficientSpaceError{check}
+	}
+	return check, nil
+}
+
+// backupSizes returns the sizes of the most recent complete backups that still exist in homeDir.
+func backupSizes(client CpanelAPI, homeDir string, backups []FullBackup) ([]int64, error) {
+	files, err := client.Fileman.List(homeDir)
+	if err != nil {
+		return nil, err
+	}
+	sizeOf := make(map[string]int64, len(files))
+	for _, file := range files {
+		sizeOf[file.Name] = file.Size
+	}
+
+	var sizes []int64
+	for i := len(backups) - 1; i >= 0 && len(sizes) < estimateFromBackups; i-- {
+		if !backups[i].Complete() {
+			continue
+		}
+		if size, ok := sizeOf[backups[i].File]; ok {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,52 @@
+package cpanel
+
+// QuotaAPI wraps the cPanel Quota module.
+type QuotaAPI struct {
+	gw APIGateway
+}
+
+// QuotaInfo describes the disk quota of the account.
+// A limit of 0 means the account has no limit.
+type QuotaInfo struct {
+	MegabyteLimit   float64
+	MegabytesUsed   float64
+	MegabytesRemain float64
+	InodeLimit      int64
+	InodesUsed      int64
+	InodesRemain    int64
+}
+
+// Unlimited reports whether the account has no disk quota.
+func (q QuotaInfo) Unlimited() bool {
+	return q.MegabyteLimit == 0
+}
+
+type quotaInfoAPIResponse struct {
+	BaseUAPIResponse
+	Data struct {
+		MegabyteLimit   Number `json:"megabyte_limit"`
+		MegabytesUsed   Number `json:"megabytes_used"`
+		MegabytesRemain Number `json:"megabytes_remain"`
+		InodeLimit      Number `json:"inode_limit"`
+		InodesUsed      Number `json:"inodes_used"`
+		InodesRemain    Number `json:"inodes_remain"`
+	} `json:"data"`
+}
+
+// Get returns the disk quota of the account.
+func (q QuotaAPI) Get() (QuotaInfo, error) {
+	var out quotaInfoAPIResponse
+	err := q.gw.UAPI("Quota", "get_quota_info", Args{}, &out)
+	if err != nil {
+		return QuotaInfo{}, err
+	}
+
+	return QuotaInfo{
+		MegabyteLimit:   float64(out.Data.MegabyteLimit),
+		MegabytesUsed:   float64(out.Data.MegabytesUsed),
+		MegabytesRemain: float64(out.Data.MegabytesRemain),
+		InodeLimit:      out.Data.InodeLimit.Int64(),
+		InodesUsed:      out.Data.InodesUsed.Int64(),
+		InodesRemain:    out.Data.InodesRemain.Int64(),
+	}, nil
+}
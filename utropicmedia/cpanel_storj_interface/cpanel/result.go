@@ -0,0 +1,26 @@
+package cpanel
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number decodes numeric values that cPanel returns either as JSON numbers or as strings.
+// Values that are not numeric, such as "unlimited", decode as 0.
+type Number float64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Number) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+	if err != nil {
+		*n = 0
+		return nil
+	}
+	*n = Number(value)
+	return nil
+}
+
+// Int64 returns the number truncated to an integer.
+func (n Number) Int64() int64 {
+	return int64(n)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package cpanel
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users on the file system holding path.
+// It reports false when path is not local, e.g. when the tool runs on another machine than cPanel.
+func freeSpace(path string) (int64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
@@ -0,0 +1,6 @@
+package cpanel
+
+// freeSpace is not supported on Windows, where the cPanel home directory is never local.
+func freeSpace(path string) (int64, bool) {
+	return 0, false
+}
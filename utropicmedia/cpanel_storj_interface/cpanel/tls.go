@@ -0,0 +1,83 @@
+package cpanel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSConfig builds the TLS configuration used to connect to the cPanel instance.
+// By default the certificate is verified against the system roots.
+// CAFile replaces the system roots with the given PEM bundle,
+// PinnedCertSHA256 trusts exactly one (possibly self-signed) certificate
+// and Insecure switches verification off.
+func (config ConfigcPanel) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: config.HostName,
+	}
+
+	if config.Insecure {
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.PinnedCertSHA256 != "" {
+		pin, err := parseFingerprint(config.PinnedCertSHA256)
+		if err != nil {
+			return nil, err
+		}
+		// The pin replaces chain verification, so self-signed certificates are accepted
+		// as long as the leaf certificate matches the fingerprint.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("cPanel did not present a certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], pin) {
+				return fmt.Errorf("cPanel certificate fingerprint %s does not match pinned fingerprint %s", formatFingerprint(sum[:]), formatFingerprint(pin))
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseFingerprint normalizes a SHA-256 fingerprint given as hex,
+// with or without colons, e.g. as printed by `openssl x509 -fingerprint -sha256`.
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	normalized := strings.ToLower(strings.Replace(strings.TrimSpace(fingerprint), ":", "", -1))
+	normalized = strings.TrimPrefix(normalized, "sha256=")
+	raw, err := hex.DecodeString(normalized)
+	if err != nil || len(raw) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA-256 certificate fingerprint: %q", fingerprint)
+	}
+	return raw, nil
+}
+
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -1,5 +1,15 @@
 module utropicmedia/cpanel_storj_interface
 
-go 1.13
+go 1.14
 
-require storj.io/storj v0.34.3
+require (
+	github.com/btcsuite/btcutil v0.0.0-20180706230648-ab6388e0c60a
+	github.com/gogo/protobuf v1.2.1
+	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa // indirect
+	golang.org/x/sys v0.0.0-20200113162924-86b910548bc1 // indirect
+	google.golang.org/genproto v0.0.0-20200115191322-ca5a22157cba // indirect
+	google.golang.org/grpc v1.26.0 // indirect
+	storj.io/common v0.0.0-20200221161141-79b008e3eff0
+	storj.io/drpc v0.0.8 // indirect
+	storj.io/uplink v0.0.0-20200221171743-26268cdd3552
+)
@@ -0,0 +1,254 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package hooks runs the commands configured to run around a backup, e.g. to put a site
+// into maintenance mode before the backup or to check the upload afterwards.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// Stages at which hooks run.
+const (
+	// PreBackup hooks run before cPanel generates or selects the backup.
+	PreBackup = "preBackup"
+	// PostBackup hooks run when the backup is ready, before it is uploaded.
+	PostBackup = "postBackup"
+	// PostUpload hooks run after the backup was uploaded.
+	PostUpload = "postUpload"
+	// OnFailure hooks run when the run fails, including when another hook failed it.
+	OnFailure = "onFailure"
+)
+
+// Policies deciding what a failing hook does to the run.
+const (
+	// PolicyAbort fails the run, the default.
+	PolicyAbort = "abort"
+	// PolicyContinue logs the failure and continues the run.
+	PolicyContinue = "continue"
+)
+
+// DefaultTimeout is how long a hook may run when no timeout is configured.
+const DefaultTimeout = 5 * time.Minute
+
+// outputLimit is the number of bytes of the output of a failed hook quoted in its error.
+const outputLimit = 1024
+
+// Config lists the hooks of a profile by stage.
+type Config struct {
+	PreBackup  []Hook `json:"preBackup"`
+	PostBackup []Hook `json:"postBackup"`
+	PostUpload []Hook `json:"postUpload"`
+	OnFailure  []Hook `json:"onFailure"`
+}
+
+// Hook is a command run by /bin/sh -c (cmd /C on Windows).
+type Hook struct {
+	Command string `json:"command"`
+	// Dir is the working directory of the command, the current directory by default.
+	Dir string `json:"dir"`
+	// Timeout is how long the command may run, e.g. "30s", 5m by default.
+	Timeout string `json:"timeout"`
+	// OnError is abort (the default) or continue.
+	OnError string `json:"onError"`
+}
+
+// Env describes the job to the hooks, as STORJ_CPANEL_* environment variables.
+type Env struct {
+	Job       string
+	Profile   string
+	Host      string
+	Account   string
+	FileName  string
+	Bucket    string
+	ObjectKey string
+	Bytes     int64
+	SHA256    string
+	// Status is running, success or failed.
+	Status string
+	// Stage is the stage of the run that failed, for onFailure hooks.
+	Stage string
+	Error string
+}
+
+// ConfigError is a problem with a field of the hook configuration.
+type ConfigError struct {
+	// Field is the JSON path of the field, e.g. "preBackup[0].timeout".
+	Field   string
+	Message string
+}
+
+func (err ConfigError) Error() string {
+	return err.Field + ": " + err.Message
+}
+
+// Check validates the configuration and returns every problem found.
+func (config Config) Check() []ConfigError {
+	var problems []ConfigError
+	for _, stage := range config.stages() {
+		for i, hook := range stage.hooks {
+			path := fmt.Sprintf("%s[%d]", stage.name, i)
+			if strings.TrimSpace(hook.Command) == "" {
+				problems = append(problems, ConfigError{Field: path + ".command", Message: "is required"})
+			}
+			if _, err := hook.timeout(); err != nil {
+				problems = append(problems, ConfigError{Field: path + ".timeout", Message: err.Error()})
+			}
+			switch hook.OnError {
+			case "", PolicyAbort, PolicyContinue:
+			default:
+				problems = append(problems, ConfigError{Field: path + ".onError", Message: fmt.Sprintf("unknown policy %q, expected abort or continue", hook.OnError)})
+			}
+		}
+	}
+	return problems
+}
+
+type stage struct {
+	name  string
+	hooks []Hook
+}
+
+func (config Config) stages() []stage {
+	return []stage{
+		{PreBackup, config.PreBackup},
+		{PostBackup, config.PostBackup},
+		{PostUpload, config.PostUpload},
+		{OnFailure, config.OnFailure},
+	}
+}
+
+// IsZero reports whether no hooks are configured.
+func (config Config) IsZero() bool {
+	for _, stage := range config.stages() {
+		if len(stage.hooks) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (hook Hook) timeout() (time.Duration, error) {
+	if hook.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(hook.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q, expected e.g. \"30s\"", hook.Timeout)
+	}
+	return timeout, nil
+}
+
+// Run runs the hooks of stage one after the other with the job described by env.
+// A failing hook with the abort policy stops the stage and its error is returned;
+// failures of hooks with the continue policy, and of all onFailure hooks, are only logged.
+func (config Config) Run(ctx context.Context, stageName string, env Env) error {
+	for _, stage := range config.stages() {
+		if stage.name != stageName {
+			continue
+		}
+		for i, hook := range stage.hooks {
+			log := logger.With(logging.F("hook", fmt.Sprintf("%s[%d]", stageName, i)))
+			err := hook.run(ctx, log, stageName, env)
+			if err == nil {
+				continue
+			}
+			if stageName == OnFailure || hook.OnError == PolicyContinue {
+				log.Warn("Hook failed, continuing", logging.F("error", err))
+				continue
+			}
+			return fmt.Errorf("%s hook %q failed: %v", stageName, hook.Command, err)
+		}
+	}
+	return nil
+}
+
+func (hook Hook) run(ctx context.Context, log *logging.Logger, stageName string, env Env) error {
+	timeout, err := hook.timeout()
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	cmd := command(hook.Command)
+	cmd.Dir = hook.Dir
+	cmd.Env = append(os.Environ(), env.variables(stageName)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	log.Info("Running hook", logging.F("command", hook.Command))
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+	case <-timer.C:
+		kill(cmd)
+		<-done
+		err = fmt.Errorf("timed out after %v", timeout)
+	case <-ctx.Done():
+		kill(cmd)
+		<-done
+		err = ctx.Err()
+	}
+
+	log.Debug("Hook output", logging.F("output", output.String()))
+	if err != nil {
+		if text := strings.TrimSpace(lastBytes(output.String(), outputLimit)); text != "" {
+			err = fmt.Errorf("%v: %s", err, text)
+		}
+		return err
+	}
+	log.Info("Hook finished", logging.F("duration", time.Since(started).Round(time.Millisecond)))
+	return nil
+}
+
+// variables returns the environment variables describing the job to a hook of stage.
+func (env Env) variables(stageName string) []string {
+	return []string{
+		"STORJ_CPANEL_HOOK=" + stageName,
+		"STORJ_CPANEL_JOB=" + env.Job,
+		"STORJ_CPANEL_PROFILE=" + env.Profile,
+		"STORJ_CPANEL_HOST=" + env.Host,
+		"STORJ_CPANEL_ACCOUNT=" + env.Account,
+		"STORJ_CPANEL_FILENAME=" + env.FileName,
+		"STORJ_CPANEL_BUCKET=" + env.Bucket,
+		"STORJ_CPANEL_OBJECT_KEY=" + env.ObjectKey,
+		"STORJ_CPANEL_BYTES=" + strconv.FormatInt(env.Bytes, 10),
+		"STORJ_CPANEL_SHA256=" + env.SHA256,
+		"STORJ_CPANEL_STATUS=" + env.Status,
+		"STORJ_CPANEL_STAGE=" + env.Stage,
+		"STORJ_CPANEL_ERROR=" + env.Error,
+	}
+}
+
+// lastBytes returns the last n bytes of text.
+func lastBytes(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return "..." + text[len(text)-n:]
+}
+
+// command returns the command running line with the shell.
+func command(line string) *exec.Cmd {
+	cmd := exec.Command(shell[0], append(shell[1:], line)...)
+	setProcessGroup(cmd)
+	return cmd
+}
@@ -0,0 +1,17 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package hooks
+
+import "os/exec"
+
+// shell runs the command line of a hook.
+var shell = []string{"cmd", "/C"}
+
+// setProcessGroup does nothing on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// kill stops the command, but not the processes it started.
+func kill(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
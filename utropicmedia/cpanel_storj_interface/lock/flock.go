@@ -0,0 +1,41 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var (
+	errFlockBusy        = errors.New("lock is held")
+	errFlockUnsupported = errors.New("flock is not supported")
+)
+
+// flock locks file exclusively without waiting.
+func flock(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	switch err {
+	case nil:
+		return nil
+	case syscall.EWOULDBLOCK:
+		return errFlockBusy
+	case syscall.ENOLCK, syscall.EOPNOTSUPP, syscall.EINVAL:
+		return errFlockUnsupported
+	}
+	return err
+}
+
+// processRunning reports whether the process pid runs on this machine.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,32 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package lock
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	errFlockBusy        = errors.New("lock is held")
+	errFlockUnsupported = errors.New("flock is not supported")
+)
+
+// flock is not supported on Windows, where locks are held by creating the lock file.
+func flock(file *os.File) error {
+	return errFlockUnsupported
+}
+
+// processRunning reports whether the process pid runs on this machine.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package lock keeps runs against the same cPanel account from overlapping with lock files.
+//
+// The lock file is locked with flock, which the system releases when the holder dies.
+// Where flock is not supported, e.g. on Windows and some network file systems, the lock is
+// held by creating the file, and a lock left behind by a process that is no longer running
+// on this machine is taken over.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Holder describes the run holding a lock, recorded in the lock file.
+type Holder struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Job      string    `json:"job,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+// Lock is a held lock.
+type Lock struct {
+	Path string
+	// Stale is the holder of a lock that was left behind and taken over, nil otherwise.
+	Stale *Holder
+
+	file   *os.File
+	flocks bool
+}
+
+// LockedError is returned when another run holds the lock.
+type LockedError struct {
+	Path string
+	// Holder describes the other run, if the lock file could be read.
+	Holder *Holder
+}
+
+func (err *LockedError) Error() string {
+	if err.Holder == nil {
+		return fmt.Sprintf("locked by another run (%s)", err.Path)
+	}
+	holder := err.Holder
+	return fmt.Sprintf("locked by %s run %s (pid %d on %s) since %s (%s)",
+		holder.Command, holder.Job, holder.PID, holder.Hostname, holder.Started.Format(time.RFC3339), err.Path)
+}
+
+// IsLocked reports whether err was returned because another run holds the lock.
+func IsLocked(err error) bool {
+	var locked *LockedError
+	return errors.As(err, &locked)
+}
+
+// unsafeName matches characters that are replaced in the names of lock files.
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// AccountPath returns the path of the lock file of a cPanel account in dir.
+func AccountPath(dir, host, account string) string {
+	return filepath.Join(dir, unsafeName.ReplaceAllString(host, "_")+"_"+unsafeName.ReplaceAllString(account, "_")+".lock")
+}
+
+// Acquire locks the lock file at path for holder, creating the file and its directory if needed.
+// It fails with a LockedError when another run holds the lock.
+func Acquire(path string, holder Holder) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if holder.PID == 0 {
+		holder.PID = os.Getpid()
+	}
+	if holder.Hostname == "" {
+		holder.Hostname, _ = os.Hostname()
+	}
+	if holder.Started.IsZero() {
+		holder.Started = time.Now()
+	}
+
+	lock, err := acquireFlock(path)
+	if err == errFlockUnsupported {
+		lock, err = acquireExclusive(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock.write(holder); err != nil {
+		_ = lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// acquireFlock locks the file at path with flock. A holder recorded in the file is
+// left behind by a run that ended without releasing the lock.
+func acquireFlock(path string) (*Lock, error) {
+	created := true
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		created = false
+		file, err = os.OpenFile(path, os.O_RDWR, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(file); err != nil {
+		_ = file.Close()
+		switch err {
+		case errFlockBusy:
+			return nil, &LockedError{Path: path, Holder: readHolder(path)}
+		case errFlockUnsupported:
+			// The file must not exist for acquireExclusive.
+			if created {
+				_ = os.Remove(path)
+			}
+		}
+		return nil, err
+	}
+	return &Lock{Path: path, Stale: readHolder(path), file: file, flocks: true}, nil
+}
+
+// acquireExclusive locks by creating the file at path, taking it over when its holder
+// no longer runs on this machine.
+func acquireExclusive(path string) (*Lock, error) {
+	var stale *Holder
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			return &Lock{Path: path, Stale: stale, file: file}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		holder := readHolder(path)
+		if !abandoned(path, holder) {
+			return nil, &LockedError{Path: path, Holder: holder}
+		}
+		stale = holder
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, &LockedError{Path: path, Holder: readHolder(path)}
+}
+
+// abandonedAfter is how old a lock file without holder must be to be taken over.
+// The holder is written right after the file is created, unless the run died in between.
+const abandonedAfter = time.Minute
+
+// abandoned reports whether the lock file at path, held by holder, was left behind by a run
+// that no longer runs on this machine.
+func abandoned(path string, holder *Holder) bool {
+	if holder == nil {
+		info, err := os.Stat(path)
+		return err == nil && time.Since(info.ModTime()) > abandonedAfter
+	}
+	hostname, _ := os.Hostname()
+	return holder.Hostname == hostname && !processRunning(holder.PID)
+}
+
+func (lock *Lock) write(holder Holder) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	if err := lock.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = lock.file.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// readHolder returns the holder recorded in the lock file at path, or nil.
+func readHolder(path string) *Holder {
+	data, err := ioutil.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var holder Holder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return nil
+	}
+	return &holder
+}
+
+// Release releases the lock. The file of a flock is emptied but kept, as removing it could let
+// two runs lock different files; otherwise the file is removed.
+func (lock *Lock) Release() error {
+	if lock.flocks {
+		_ = lock.file.Truncate(0)
+		return lock.file.Close()
+	}
+	err := lock.file.Close()
+	if removeErr := os.Remove(lock.Path); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package logging writes leveled log entries with fields, as text or JSON.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+// Levels in increasing severity.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (level Level) String() string {
+	if level < Debug || level > Error {
+		return "level(" + strconv.Itoa(int(level)) + ")"
+	}
+	return levelNames[level]
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error".
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(level), nil
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return Warn, nil
+	}
+	return Info, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+}
+
+// Format is how log entries are written.
+type Format int
+
+// Formats of log entries.
+const (
+	// Text writes one line per entry: time, level, message and key=value fields.
+	Text Format = iota
+	// JSON writes one JSON object per line.
+	JSON
+)
+
+// ParseFormat parses "text" or "json".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return Text, fmt.Errorf("invalid log format %q, expected text or json", name)
+}
+
+// Field is a named value attached to a log entry, e.g. the account or object key.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// redacted replaces credentials in log entries.
+const redacted = "[redacted]"
+
+// Redact hides a credential such as a password, API key or access grant.
+// Empty values stay empty, so that it is still visible whether the credential is set.
+func Redact(credential string) string {
+	if credential == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Logger writes log entries of at least its level.
+// Loggers derived with With share the output of their parent.
+// The nil Logger discards all entries.
+type Logger struct {
+	output *output
+	level  Level
+	format Format
+	fields []Field
+}
+
+// output serializes writes of all loggers sharing it.
+type output struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// New returns a logger writing entries of level and above to writer in format.
+func New(writer io.Writer, level Level, format Format) *Logger {
+	return &Logger{
+		output: &output{writer: writer},
+		level:  level,
+		format: format,
+	}
+}
+
+// Default returns the logger used until one is configured: text entries of level Info to stdout.
+func Default() *Logger {
+	return New(os.Stdout, Info, Text)
+}
+
+// Discard returns a logger that writes nothing.
+func Discard() *Logger {
+	return nil
+}
+
+// With returns a logger adding fields to every entry.
+func (logger *Logger) With(fields ...Field) *Logger {
+	if logger == nil {
+		return nil
+	}
+	derived := *logger
+	derived.fields = append(append([]Field(nil), logger.fields...), fields...)
+	return &derived
+}
+
+// Enabled reports whether entries of level are written.
+func (logger *Logger) Enabled(level Level) bool {
+	return logger != nil && level >= logger.level
+}
+
+// Debug writes a debug entry, used for tracing.
+func (logger *Logger) Debug(message string, fields ...Field) {
+	logger.log(Debug, message, fields)
+}
+
+// Info writes an entry about progress.
+func (logger *Logger) Info(message string, fields ...Field) {
+	logger.log(Info, message, fields)
+}
+
+// Warn writes an entry about a problem that does not stop the operation.
+func (logger *Logger) Warn(message string, fields ...Field) {
+	logger.log(Warn, message, fields)
+}
+
+// Error writes an entry about a failed operation.
+func (logger *Logger) Error(message string, fields ...Field) {
+	logger.log(Error, message, fields)
+}
+
+func (logger *Logger) log(level Level, message string, fields []Field) {
+	if !logger.Enabled(level) {
+		return
+	}
+	now := time.Now()
+	all := append(append([]Field(nil), logger.fields...), fields...)
+
+	var buf bytes.Buffer
+	if logger.format == JSON {
+		writeJSON(&buf, now, level, message, all)
+	} else {
+		writeText(&buf, now, level, message, all)
+	}
+
+	logger.output.mu.Lock()
+	defer logger.output.mu.Unlock()
+	_, _ = logger.output.writer.Write(buf.Bytes())
+}
+
+func writeText(buf *bytes.Buffer, now time.Time, level Level, message string, fields []Field) {
+	buf.WriteString(now.Format(time.RFC3339))
+	buf.WriteByte(' ')
+	fmt.Fprintf(buf, "%-5s", strings.ToUpper(level.String()))
+	buf.WriteByte(' ')
+	buf.WriteString(message)
+	for _, field := range fields {
+		buf.WriteByte(' ')
+		buf.WriteString(field.Key)
+		buf.WriteByte('=')
+		text := fmt.Sprint(plain(field.Value))
+		if text == "" || strings.ContainsAny(text, " \t\n\"=") {
+			text = strconv.Quote(text)
+		}
+		buf.WriteString(text)
+	}
+	buf.WriteByte('\n')
+}
+
+func writeJSON(buf *bytes.Buffer, now time.Time, level Level, message string, fields []Field) {
+	buf.WriteString(`{"time":`)
+	writeJSONValue(buf, now.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(buf, message)
+	for _, field := range fields {
+		buf.WriteByte(',')
+		writeJSONValue(buf, field.Key)
+		buf.WriteByte(':')
+		writeJSONValue(buf, plain(field.Value))
+	}
+	buf.WriteString("}\n")
+}
+
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
+
+// plain converts values that do not format well as they are.
+func plain(value interface{}) interface{} {
+	switch value := value.(type) {
+	case error:
+		if value == nil {
+			return nil
+		}
+		return value.Error()
+	case time.Duration:
+		return value.Round(time.Millisecond).String()
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case fmt.Stringer:
+		return value.String()
+	}
+	return value
+}
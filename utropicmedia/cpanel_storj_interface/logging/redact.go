@@ -0,0 +1,54 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package logging
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// sensitiveNames matches names of parameters and fields holding credentials.
+var sensitiveNames = regexp.MustCompile(`(?i)pass|secret|token|key|grant|scope|auth|cookie|session`)
+
+// sensitiveJSON matches JSON string members whose name suggests a credential.
+var sensitiveJSON = regexp.MustCompile(`"([^"]*(?i:pass|secret|token|key|grant|scope|auth|cookie|session)[^"]*)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+
+// IsSensitive reports whether a parameter or field named name likely holds a credential.
+func IsSensitive(name string) bool {
+	return sensitiveNames.MatchString(name)
+}
+
+// RedactValues returns a copy of values with the values of sensitive parameters redacted.
+func RedactValues(values url.Values) url.Values {
+	copied := make(url.Values, len(values))
+	for name, list := range values {
+		if IsSensitive(name) {
+			list = []string{redacted}
+		}
+		copied[name] = list
+	}
+	return copied
+}
+
+// RedactURL returns rawURL with user information and the values of sensitive query parameters redacted.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(redacted)
+	}
+	parsed.RawQuery = RedactValues(parsed.Query()).Encode()
+	return parsed.String()
+}
+
+// RedactJSON redacts the string values of members of a JSON document whose name suggests a credential.
+func RedactJSON(data []byte) string {
+	return sensitiveJSON.ReplaceAllStringFunc(string(data), func(member string) string {
+		name := member[:strings.Index(member[1:], `"`)+2]
+		return name + `:"` + redacted + `"`
+	})
+}
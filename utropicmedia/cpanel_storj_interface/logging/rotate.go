@@ -0,0 +1,95 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DefaultMaxBackups is the number of rotated log files kept when none is configured.
+const DefaultMaxBackups = 5
+
+// RotatingFile is a log file that is rotated when it grows beyond MaxBytes:
+// path is renamed to path.1, path.1 to path.2 and so on, keeping MaxBackups old files.
+type RotatingFile struct {
+	Path string
+	// MaxBytes is the size at which the file is rotated, 0 never rotates it.
+	MaxBytes int64
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens the log file at path for appending, creating it readable by the owner only.
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	rotating := &RotatingFile{Path: path, MaxBytes: maxBytes, MaxBackups: maxBackups}
+	if err := rotating.open(); err != nil {
+		return nil, err
+	}
+	return rotating, nil
+}
+
+func (rotating *RotatingFile) open() error {
+	file, err := os.OpenFile(rotating.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	rotating.file = file
+	rotating.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would exceed MaxBytes.
+func (rotating *RotatingFile) Write(p []byte) (int, error) {
+	rotating.mu.Lock()
+	defer rotating.mu.Unlock()
+
+	if rotating.MaxBytes > 0 && rotating.size > 0 && rotating.size+int64(len(p)) > rotating.MaxBytes {
+		if err := rotating.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files, moves the current file to path.1 and starts a new one.
+func (rotating *RotatingFile) rotate() error {
+	if err := rotating.file.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(rotating.backup(rotating.MaxBackups))
+	for i := rotating.MaxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(rotating.backup(i), rotating.backup(i+1))
+	}
+	if err := os.Rename(rotating.Path, rotating.backup(1)); err != nil {
+		return err
+	}
+	return rotating.open()
+}
+
+func (rotating *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", rotating.Path, n)
+}
+
+// Close closes the file.
+func (rotating *RotatingFile) Close() error {
+	rotating.mu.Lock()
+	defer rotating.mu.Unlock()
+	return rotating.file.Close()
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package metrics keeps gauges and counters and exposes them in the Prometheus text format,
+// served over HTTP or pushed to a Pushgateway.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types.
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Labels distinguish the samples of a metric, e.g. {"account": "shop"}.
+type Labels map[string]string
+
+// Registry holds the current value of every metric.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	typ     string
+	help    string
+	samples map[string]float64
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Describe declares the type and help text of the metric name.
+func (registry *Registry) Describe(name, typ, help string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	f := registry.family(name)
+	f.typ, f.help = typ, help
+}
+
+// Set sets the sample of the metric name with labels to value.
+func (registry *Registry) Set(name string, labels Labels, value float64) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.family(name).samples[labels.String()] = value
+}
+
+// Add adds delta to the sample of the metric name with labels.
+func (registry *Registry) Add(name string, labels Labels, delta float64) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.family(name).samples[labels.String()] += delta
+}
+
+// family returns the metric name, creating it as an untyped metric.
+// The caller must hold the lock.
+func (registry *Registry) family(name string) *family {
+	f, ok := registry.families[name]
+	if !ok {
+		f = &family{name: name, typ: "untyped", samples: make(map[string]float64)}
+		registry.families[name] = f
+	}
+	return f
+}
+
+// WriteTo writes all metrics that have samples in the Prometheus text format.
+func (registry *Registry) WriteTo(w io.Writer) (int64, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	names := make([]string, 0, len(registry.families))
+	for name, f := range registry.families {
+		if len(f.samples) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	counter := &countingWriter{writer: bufio.NewWriter(w)}
+	for _, name := range names {
+		f := registry.families[name]
+		if f.help != "" {
+			fmt.Fprintf(counter, "# HELP %s %s\n", name, escapeHelp(f.help))
+		}
+		fmt.Fprintf(counter, "# TYPE %s %s\n", name, f.typ)
+
+		labels := make([]string, 0, len(f.samples))
+		for label := range f.samples {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			fmt.Fprintf(counter, "%s%s %s\n", name, label, formatValue(f.samples[label]))
+		}
+	}
+	if err := counter.writer.Flush(); err != nil {
+		return counter.n, err
+	}
+	return counter.n, counter.err
+}
+
+// ServeHTTP serves the metrics, so that the registry can be registered as the /metrics handler.
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = registry.WriteTo(w)
+}
+
+// String renders the labels as in the text format, sorted by name, e.g. {account="shop",host="example.com"}.
+func (labels Labels) String() string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values; help texts are escaped alike, except for quotes.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		// Whole numbers like timestamps and byte counts are written without exponent.
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	writer *bufio.Writer
+	n      int64
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
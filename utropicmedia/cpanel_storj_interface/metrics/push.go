@@ -0,0 +1,70 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pushClient pushes metrics, giving up on gateways that do not answer.
+var pushClient = &http.Client{Timeout: 30 * time.Second}
+
+// Push sends the metrics of registry to the Pushgateway at gatewayURL, grouped by job and grouping.
+// Metrics of the group with the same names are replaced, other metrics of the group are kept,
+// so that e.g. the time of the last success survives the push of a failed run.
+func Push(ctx context.Context, gatewayURL, job string, grouping Labels, registry *Registry) error {
+	target := strings.TrimRight(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	names := make([]string, 0, len(grouping))
+	for name := range grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target += "/" + groupingPath(name, grouping[name])
+	}
+
+	var body bytes.Buffer
+	if _, err := registry.WriteTo(&body); err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, target, &body)
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	response, err := pushClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode/100 != 2 {
+		text, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("pushgateway answered %s: %s", response.Status, bytes.TrimSpace(text))
+	}
+	return nil
+}
+
+// groupingPath encodes a grouping label for the URL path, in base64 when the value
+// cannot be part of a path segment.
+func groupingPath(name, value string) string {
+	switch {
+	case value == "":
+		return url.PathEscape(name) + "@base64/="
+	case strings.Contains(value, "/"):
+		return url.PathEscape(name) + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return url.PathEscape(name) + "/" + url.PathEscape(value)
+}
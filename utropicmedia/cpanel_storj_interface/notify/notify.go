@@ -0,0 +1,424 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+// Package notify tells people about the outcome of backup runs by email,
+// generic webhooks and Slack or Mattermost compatible webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Sink types.
+const (
+	TypeSMTP    = "smtp"
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+)
+
+// Policies deciding which runs a sink is notified of.
+const (
+	// PolicyAlways notifies of every run.
+	PolicyAlways = "always"
+	// PolicyFailure only notifies of failed runs.
+	PolicyFailure = "failure"
+	// PolicySuccess only notifies of successful runs.
+	PolicySuccess = "success"
+	// PolicyDigest collects runs and sends one message about all of them per digest interval.
+	PolicyDigest = "digest"
+)
+
+// Results of failed and interrupted runs, as in the run report.
+const (
+	resultFailed      = "failed"
+	resultInterrupted = "interrupted"
+)
+
+// DefaultDigestInterval is how often digests are sent when no interval is configured.
+const DefaultDigestInterval = 24 * time.Hour
+
+// DefaultDigestFile keeps the runs collected for digests when no file is configured.
+const DefaultDigestFile = "./config/notify_digest.json"
+
+// Config lists where notifications are sent.
+type Config struct {
+	Sinks []Sink `json:"sinks"`
+	// DigestFile keeps the runs collected for sinks with the digest policy until the digest is sent.
+	DigestFile string `json:"digestFile"`
+}
+
+// Sink is one destination of notifications.
+type Sink struct {
+	// Name identifies the sink in log entries and the digest file.
+	Name string `json:"name"`
+	// Type is smtp, webhook or slack.
+	Type string `json:"type"`
+	// Policy is always (the default), failure, success or digest.
+	Policy string `json:"policy"`
+	// DigestInterval is how often a digest is sent, e.g. "24h" (the default).
+	DigestInterval string `json:"digestInterval"`
+	// Commands limits notifications to runs of these commands, e.g. ["store"]. Empty means all.
+	Commands []string `json:"commands"`
+
+	// Subject is the template of the email subject or the title of the message.
+	Subject string `json:"subject"`
+	// Template is the template of the message about a run.
+	Template string `json:"template"`
+	// DigestTemplate is the template of a digest.
+	DigestTemplate string `json:"digestTemplate"`
+
+	SMTP    SMTPConfig    `json:"smtp"`
+	Webhook WebhookConfig `json:"webhook"`
+	Slack   SlackConfig   `json:"slack"`
+}
+
+// Event is the outcome of a run.
+type Event struct {
+	Job      string    `json:"job"`
+	Command  string    `json:"command"`
+	Host     string    `json:"host,omitempty"`
+	Account  string    `json:"account,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Result is success, failed, interrupted or skipped.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Summary describes in one line what the run did.
+	Summary string `json:"summary"`
+	// Report is the machine-readable report of the run.
+	Report interface{} `json:"report,omitempty"`
+}
+
+// Failed reports whether the run failed. An interrupted run counts as failed, as it did not complete.
+func (event Event) Failed() bool {
+	return event.Result == resultFailed || event.Result == resultInterrupted
+}
+
+// Duration is how long the run took.
+func (event Event) Duration() time.Duration {
+	return event.Finished.Sub(event.Started).Round(time.Second)
+}
+
+// Digest is the template data of a digest.
+type Digest struct {
+	Events []Event
+	Failed int
+	Since  time.Time
+	Until  time.Time
+}
+
+// Message is a rendered notification.
+type Message struct {
+	Subject string
+	Body    string
+	// Failed reports whether the message is about a failure, e.g. to color it.
+	Failed bool
+	// Events are the runs the message is about.
+	Events []Event
+}
+
+const defaultSubject = `[storj-cpanel] {{.Command}} {{.Result}}{{if .Account}} for {{.Account}}@{{.Host}}{{end}}`
+
+const defaultTemplate = `{{.Command}} {{.Result}}{{if .Account}} for {{.Account}}@{{.Host}}{{end}}
+Job: {{.Job}}
+Started: {{.Started.Format "2006-01-02 15:04:05 MST"}} ({{.Duration}})
+{{if .Summary}}{{.Summary}}
+{{end}}{{if .Error}}Error: {{.Error}}
+{{end}}`
+
+const defaultDigestSubject = `[storj-cpanel] digest: {{len .Events}} runs, {{.Failed}} failed`
+
+const defaultDigestTemplate = `{{len .Events}} runs since {{.Since.Format "2006-01-02 15:04 MST"}}, {{.Failed}} failed
+{{range .Events}}
+{{.Finished.Format "2006-01-02 15:04"}} {{.Command}} {{.Result}}{{if .Account}} {{.Account}}@{{.Host}}{{end}}{{if .Summary}}: {{.Summary}}{{end}}{{if .Error}}
+    Error: {{.Error}}{{end}}{{end}}
+`
+
+// ConfigError is a problem with a field of the notification configuration.
+type ConfigError struct {
+	// Field is the JSON path of the field, e.g. "sinks[0].smtp.host".
+	Field   string
+	Message string
+}
+
+func (err ConfigError) Error() string {
+	return err.Field + ": " + err.Message
+}
+
+// Check validates the configuration and returns every problem found.
+func (config Config) Check() []ConfigError {
+	var problems []ConfigError
+	names := make(map[string]bool)
+	for i, sink := range config.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		report := func(field, format string, args ...interface{}) {
+			problems = append(problems, ConfigError{Field: path + "." + field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if names[sink.name(i)] {
+			report("name", "sink %q is configured more than once", sink.name(i))
+		}
+		names[sink.name(i)] = true
+
+		switch sink.Type {
+		case TypeSMTP:
+			sink.SMTP.check(func(field, message string) { report("smtp."+field, "%s", message) })
+		case TypeWebhook:
+			if err := checkURL(sink.Webhook.URL); err != nil {
+				report("webhook.url", "%v", err)
+			}
+		case TypeSlack:
+			if err := checkURL(sink.Slack.URL); err != nil {
+				report("slack.url", "%v", err)
+			}
+		case "":
+			report("type", "is required, expected smtp, webhook or slack")
+		default:
+			report("type", "unknown type %q, expected smtp, webhook or slack", sink.Type)
+		}
+
+		switch sink.Policy {
+		case "", PolicyAlways, PolicyFailure, PolicySuccess, PolicyDigest:
+		default:
+			report("policy", "unknown policy %q, expected always, failure, success or digest", sink.Policy)
+		}
+		if _, err := sink.digestInterval(); err != nil {
+			report("digestInterval", "%v", err)
+		}
+
+		for _, text := range []struct{ field, text string }{
+			{"subject", sink.Subject},
+			{"template", sink.Template},
+			{"digestTemplate", sink.DigestTemplate},
+		} {
+			if _, err := template.New(text.field).Parse(text.text); err != nil {
+				report(text.field, "%v", err)
+			}
+		}
+	}
+	return problems
+}
+
+// name returns the name of the i-th sink, which defaults to its type and position.
+func (sink Sink) name(i int) string {
+	if sink.Name != "" {
+		return sink.Name
+	}
+	return fmt.Sprintf("%s-%d", sink.Type, i+1)
+}
+
+func (sink Sink) policy() string {
+	if sink.Policy == "" {
+		return PolicyAlways
+	}
+	return sink.Policy
+}
+
+func (sink Sink) digestInterval() (time.Duration, error) {
+	if sink.DigestInterval == "" {
+		return DefaultDigestInterval, nil
+	}
+	interval, err := time.ParseDuration(sink.DigestInterval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid interval %q, expected e.g. \"24h\"", sink.DigestInterval)
+	}
+	return interval, nil
+}
+
+// wants reports whether the sink is notified of event, or collects it for a digest.
+func (sink Sink) wants(event Event) bool {
+	if len(sink.Commands) > 0 {
+		found := false
+		for _, command := range sink.Commands {
+			found = found || command == event.Command
+		}
+		if !found {
+			return false
+		}
+	}
+	switch sink.policy() {
+	case PolicyFailure:
+		return event.Failed()
+	case PolicySuccess:
+		return !event.Failed()
+	}
+	return true
+}
+
+// Notify sends event to every sink whose policy wants it, and collects it for digests.
+// Digests that are due are sent as well. All sinks are tried; the errors of those that failed
+// are returned together.
+func Notify(ctx context.Context, config Config, event Event) error {
+	digests, err := openDigests(config.digestFile())
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for i, sink := range config.Sinks {
+		name := sink.name(i)
+		if !sink.wants(event) {
+			continue
+		}
+
+		var message Message
+		if sink.policy() == PolicyDigest {
+			interval, _ := sink.digestInterval()
+			events := digests.add(name, event)
+			if event.Finished.Sub(events[0].Finished) < interval {
+				continue
+			}
+			message, err = sink.renderDigest(events)
+		} else {
+			message, err = sink.render(event)
+		}
+		if err == nil {
+			err = sink.send(ctx, message)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if sink.policy() == PolicyDigest {
+			digests.clear(name)
+		}
+	}
+
+	if err := digests.save(); err != nil {
+		errs = append(errs, fmt.Sprintf("digest file: %v", err))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (config Config) digestFile() string {
+	if config.DigestFile == "" {
+		return DefaultDigestFile
+	}
+	return config.DigestFile
+}
+
+// render renders the message about event.
+func (sink Sink) render(event Event) (Message, error) {
+	subject, err := execute(sink.Subject, defaultSubject, event)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(sink.Template, defaultTemplate, event)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Subject: subject, Body: body, Failed: event.Failed(), Events: []Event{event}}, nil
+}
+
+// renderDigest renders the digest of events.
+func (sink Sink) renderDigest(events []Event) (Message, error) {
+	digest := Digest{
+		Events: events,
+		Since:  events[0].Started,
+		Until:  events[len(events)-1].Finished,
+	}
+	for _, event := range events {
+		if event.Failed() {
+			digest.Failed++
+		}
+	}
+
+	subjectTemplate := defaultDigestSubject
+	if sink.Subject != "" {
+		subjectTemplate = sink.Subject
+	}
+	subject, err := execute(subjectTemplate, defaultDigestSubject, digest)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(sink.DigestTemplate, defaultDigestTemplate, digest)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Subject: subject, Body: body, Failed: digest.Failed > 0, Events: events}, nil
+}
+
+// execute renders text, or defaultText when text is empty, with data.
+func execute(text, defaultText string, data interface{}) (string, error) {
+	if text == "" {
+		text = defaultText
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// send delivers message through the sink.
+func (sink Sink) send(ctx context.Context, message Message) error {
+	switch sink.Type {
+	case TypeSMTP:
+		return sink.SMTP.send(message)
+	case TypeWebhook:
+		return sink.Webhook.send(ctx, message)
+	case TypeSlack:
+		return sink.Slack.send(ctx, message)
+	}
+	return fmt.Errorf("unknown type %q", sink.Type)
+}
+
+// digests are the runs collected per sink for digests, kept in a file between runs.
+type digests struct {
+	path    string
+	events  map[string][]Event
+	changed bool
+}
+
+func openDigests(path string) (*digests, error) {
+	d := &digests{path: path, events: make(map[string][]Event)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.events); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return d, nil
+}
+
+func (d *digests) add(sink string, event Event) []Event {
+	d.events[sink] = append(d.events[sink], event)
+	d.changed = true
+	return d.events[sink]
+}
+
+func (d *digests) clear(sink string) {
+	delete(d.events, sink)
+	d.changed = true
+}
+
+func (d *digests) save() error {
+	if !d.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(d.events, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.path, data, 0600)
+}
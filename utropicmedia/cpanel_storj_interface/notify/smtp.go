@@ -0,0 +1,117 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig configures an email sink.
+type SMTPConfig struct {
+	Host string `json:"host"`
+	// Port defaults to 587, or 465 with ImplicitTLS.
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// ImplicitTLS connects with TLS right away (usually port 465) instead of upgrading with STARTTLS.
+	ImplicitTLS bool `json:"implicitTLS"`
+}
+
+func (config SMTPConfig) check(report func(field, message string)) {
+	if config.Host == "" {
+		report("host", "is required")
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		report("port", fmt.Sprintf("invalid port %d", config.Port))
+	}
+	if config.From == "" {
+		report("from", "is required")
+	}
+	if len(config.To) == 0 {
+		report("to", "at least one recipient is required")
+	}
+	if config.Password != "" && config.Username == "" {
+		report("username", "is required with a password")
+	}
+}
+
+func (config SMTPConfig) address() string {
+	port := config.Port
+	if port == 0 {
+		port = 587
+		if config.ImplicitTLS {
+			port = 465
+		}
+	}
+	return net.JoinHostPort(config.Host, strconv.Itoa(port))
+}
+
+// send emails message to the recipients.
+func (config SMTPConfig) send(message Message) error {
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(message.Body, "\n", "\r\n", -1))
+
+	if !config.ImplicitTLS {
+		// SendMail upgrades the connection with STARTTLS when the server offers it.
+		return smtp.SendMail(config.address(), auth, config.From, config.To, msg.Bytes())
+	}
+
+	conn, err := tls.Dial("tcp", config.address(), &tls.Config{ServerName: config.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return err
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg.Bytes()); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
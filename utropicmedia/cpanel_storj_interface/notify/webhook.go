@@ -0,0 +1,135 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpClient posts webhooks, giving up on servers that do not answer.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// WebhookConfig configures a generic webhook, which receives the runs as JSON.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Headers are added to the request, e.g. {"Authorization": "Bearer ..."}.
+	Headers map[string]string `json:"headers"`
+}
+
+// webhookPayload is the JSON body posted to generic webhooks.
+type webhookPayload struct {
+	Subject string  `json:"subject"`
+	Message string  `json:"message"`
+	Failed  bool    `json:"failed"`
+	Events  []Event `json:"events"`
+}
+
+func (config WebhookConfig) send(ctx context.Context, message Message) error {
+	return post(ctx, config.URL, config.Headers, webhookPayload{
+		Subject: message.Subject,
+		Message: message.Body,
+		Failed:  message.Failed,
+		Events:  message.Events,
+	})
+}
+
+// SlackConfig configures a Slack or Mattermost incoming webhook.
+type SlackConfig struct {
+	URL string `json:"url"`
+	// Channel, Username and IconEmoji override the defaults of the webhook.
+	Channel   string `json:"channel"`
+	Username  string `json:"username"`
+	IconEmoji string `json:"iconEmoji"`
+}
+
+// slackPayload is the body of incoming webhooks understood by Slack and Mattermost.
+type slackPayload struct {
+	Text        string            `json:"text,omitempty"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Fallback string `json:"fallback"`
+	Color    string `json:"color"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+}
+
+func (config SlackConfig) send(ctx context.Context, message Message) error {
+	color := "good"
+	if message.Failed {
+		color = "danger"
+	}
+	return post(ctx, config.URL, nil, slackPayload{
+		Channel:   config.Channel,
+		Username:  config.Username,
+		IconEmoji: config.IconEmoji,
+		Attachments: []slackAttachment{{
+			Fallback: message.Subject,
+			Color:    color,
+			Title:    message.Subject,
+			Text:     message.Body,
+		}},
+	})
+}
+
+// post sends payload as JSON to rawURL and fails unless the server answers with a 2xx status.
+func post(ctx context.Context, rawURL string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		// The error would include the URL, which often contains the token of the webhook.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s: %v", request.URL.Host, urlErr.Err)
+		}
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode/100 != 2 {
+		text, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", request.URL.Host, response.Status, bytes.TrimSpace(text))
+	}
+	return nil
+}
+
+// checkURL verifies that rawURL is an absolute http or https URL.
+func checkURL(rawURL string) error {
+	if rawURL == "" {
+		return errors.New("is required")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q, expected http:// or https://", rawURL)
+	}
+	return nil
+}
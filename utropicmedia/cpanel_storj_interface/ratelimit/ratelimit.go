@@ -0,0 +1,262 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config limits the bandwidth of a transfer.
+// Rates are given in bytes per second with an optional K, M or G suffix (powers of 1024),
+// e.g. "512K" or "10M". An empty rate or "0" means unlimited.
+type Config struct {
+	// Limit applies outside of the schedule windows.
+	Limit string `json:"limit"`
+	// Schedule overrides Limit during the given times of the day.
+	Schedule []Window `json:"schedule"`
+}
+
+// Window applies a rate between two local times of the day, given as "HH:MM".
+// A window whose end is before its start lasts over midnight.
+type Window struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Limit string `json:"limit"`
+}
+
+// IsZero reports whether the configuration sets no limit at all.
+func (config Config) IsZero() bool {
+	return config.Limit == "" && len(config.Schedule) == 0
+}
+
+// window is a parsed Window, with times in minutes since midnight.
+type window struct {
+	from, to int
+	rate     int64
+}
+
+func (w window) contains(minute int) bool {
+	if w.from <= w.to {
+		return minute >= w.from && minute < w.to
+	}
+	return minute >= w.from || minute < w.to
+}
+
+// Limiter limits the rate of the readers it wraps. One limiter may be shared
+// between readers transferring concurrently, which then share its rate.
+// A nil *Limiter does not limit anything.
+type Limiter struct {
+	rate    int64
+	windows []window
+
+	mu sync.Mutex
+	// next is the time at which the transfer of the bytes granted so far is due.
+	next time.Time
+}
+
+// New creates a limiter for config. It returns nil when config sets no limit.
+func New(config Config) (*Limiter, error) {
+	if config.IsZero() {
+		return nil, nil
+	}
+
+	rate, err := ParseRate(config.Limit)
+	if err != nil {
+		return nil, err
+	}
+	limiter := &Limiter{rate: rate}
+	for i, w := range config.Schedule {
+		from, err := parseTimeOfDay(w.From)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %d: %v", i+1, err)
+		}
+		to, err := parseTimeOfDay(w.To)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %d: %v", i+1, err)
+		}
+		rate, err := ParseRate(w.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("schedule window %d: %v", i+1, err)
+		}
+		limiter.windows = append(limiter.windows, window{from: from, to: to, rate: rate})
+	}
+	return limiter, nil
+}
+
+// ParseRate parses a rate such as "512K", "10M", "1G" or "250000" into bytes per second.
+// A trailing "B", "/s" or "B/s" is accepted as well.
+func ParseRate(rate string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(rate))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	switch s[len(s)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected e.g. \"512K\" or \"10M\"", rate)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes since midnight.
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Rate returns the rate in bytes per second in effect at the given time, 0 when unlimited.
+func (limiter *Limiter) Rate(now time.Time) int64 {
+	if limiter == nil {
+		return 0
+	}
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range limiter.windows {
+		if w.contains(minute) {
+			return w.rate
+		}
+	}
+	return limiter.rate
+}
+
+// WaitN blocks until n more bytes may be transferred or ctx is done.
+func (limiter *Limiter) WaitN(ctx context.Context, n int) error {
+	now := time.Now()
+	rate := limiter.Rate(now)
+	if rate <= 0 {
+		return ctx.Err()
+	}
+
+	limiter.mu.Lock()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	limiter.next = limiter.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	wait := limiter.next.Sub(now)
+	limiter.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunkSize returns how many bytes to read at once, about a tenth of a second worth of data,
+// so that the transfer stays smooth even with large read buffers.
+func (limiter *Limiter) chunkSize(size int) int {
+	rate := limiter.Rate(time.Now())
+	if rate <= 0 {
+		return size
+	}
+	chunk := int(rate / 10)
+	if chunk < 4096 {
+		chunk = 4096
+	}
+	if chunk < size {
+		return chunk
+	}
+	return size
+}
+
+// Reader returns reader limited to the rate of limiter.
+// It returns reader itself when limiter is nil.
+func (limiter *Limiter) Reader(ctx context.Context, reader io.Reader) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+	return &limitedReader{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+// ReadCloser is like Reader for an io.ReadCloser, e.g. the body of an HTTP response.
+func (limiter *Limiter) ReadCloser(ctx context.Context, reader io.ReadCloser) io.ReadCloser {
+	if limiter == nil {
+		return reader
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{limiter.Reader(ctx, reader), reader}
+}
+
+// ReaderAt is like Reader for an io.ReaderAt, e.g. a file uploaded in parts.
+// It returns reader itself when limiter is nil.
+func (limiter *Limiter) ReaderAt(ctx context.Context, reader io.ReaderAt) io.ReaderAt {
+	if limiter == nil {
+		return reader
+	}
+	return &limitedReaderAt{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	p = p[:r.limiter.chunkSize(len(p))]
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type limitedReaderAt struct {
+	ctx     context.Context
+	reader  io.ReaderAt
+	limiter *Limiter
+}
+
+// ReadAt reads p in chunks, as ReadAt cannot return fewer bytes than requested without an error.
+func (r *limitedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		chunk := p[read : read+r.limiter.chunkSize(len(p)-read)]
+		n, err := r.reader.ReadAt(chunk, off+int64(read))
+		read += n
+		if n > 0 {
+			if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+				return read, waitErr
+			}
+		}
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate  string
+		bytes int64
+		valid bool
+	}{
+		{"", 0, true},
+		{"0", 0, true},
+		{"250000", 250000, true},
+		{"512K", 512 << 10, true},
+		{"512k", 512 << 10, true},
+		{"10M", 10 << 20, true},
+		{"1G", 1 << 30, true},
+		{"1.5M", 3 << 19, true},
+		{" 2K ", 2 << 10, true},
+		{"10MB", 10 << 20, true},
+		{"10MiB", 10 << 20, true},
+		{"10M/s", 10 << 20, true},
+		{"10MB/s", 10 << 20, true},
+		{"100B", 100, true},
+		{"-1", 0, false},
+		{"-1M", 0, false},
+		{"fast", 0, false},
+		{"10X", 0, false},
+		{"M", 0, false},
+		{"1T", 0, false},
+	}
+	for _, test := range tests {
+		bytes, err := ParseRate(test.rate)
+		if (err == nil) != test.valid || bytes != test.bytes {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d, valid %v", test.rate, bytes, err, test.bytes, test.valid)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		limited bool
+		valid   bool
+	}{
+		{"no limit", Config{}, false, true},
+		{"limit", Config{Limit: "1M"}, true, true},
+		{"schedule only", Config{Schedule: []Window{{From: "08:00", To: "18:00", Limit: "1M"}}}, true, true},
+		{"invalid limit", Config{Limit: "lots"}, false, false},
+		{"invalid from", Config{Schedule: []Window{{From: "8am", To: "18:00", Limit: "1M"}}}, false, false},
+		{"invalid to", Config{Schedule: []Window{{From: "08:00", To: "24:00", Limit: "1M"}}}, false, false},
+		{"invalid window limit", Config{Schedule: []Window{{From: "08:00", To: "18:00", Limit: "-1"}}}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter, err := New(test.config)
+			if (err == nil) != test.valid || (limiter != nil) != test.limited {
+				t.Errorf("New() = %v, %v, want limited %v, valid %v", limiter, err, test.limited, test.valid)
+			}
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	limiter, err := New(Config{
+		Limit: "20M",
+		Schedule: []Window{
+			{From: "08:00", To: "18:00", Limit: "1M"},
+			{From: "22:00", To: "02:00", Limit: "0"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   string
+		rate int64
+	}{
+		{"07:59", 20 << 20},
+		{"08:00", 1 << 20},
+		{"17:59", 1 << 20},
+		{"18:00", 20 << 20},
+		{"22:00", 0},
+		{"00:30", 0},
+		{"02:00", 20 << 20},
+	}
+	for _, test := range tests {
+		now, err := time.Parse("15:04", test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rate := limiter.Rate(now); rate != test.rate {
+			t.Errorf("Rate() at %s = %d, want %d", test.at, rate, test.rate)
+		}
+	}
+
+	var unlimited *Limiter
+	if rate := unlimited.Rate(time.Now()); rate != 0 {
+		t.Errorf("Rate() of a nil limiter = %d, want 0", rate)
+	}
+}
+
+func TestReaders(t *testing.T) {
+	data := make([]byte, 100<<10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	limiter, err := New(Config{Limit: "1G"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	read, err := ioutil.ReadAll(limiter.Reader(ctx, bytes.NewReader(data)))
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("Reader() read %d bytes, %v, want the %d bytes of the data", len(read), err, len(data))
+	}
+
+	part := make([]byte, 50<<10)
+	n, err := limiter.ReaderAt(ctx, bytes.NewReader(data)).ReadAt(part, 30<<10)
+	if err != nil || n != len(part) || !bytes.Equal(part, data[30<<10:80<<10]) {
+		t.Errorf("ReaderAt() read %d bytes, %v, want %d bytes at the offset", n, err, len(part))
+	}
+	n, err = limiter.ReaderAt(ctx, bytes.NewReader(data)).ReadAt(part, 80<<10)
+	if err != io.EOF || n != 20<<10 {
+		t.Errorf("ReaderAt() at the end read %d bytes, %v, want %d bytes and EOF", n, err, 20<<10)
+	}
+
+	var unlimited *Limiter
+	reader := bytes.NewReader(data)
+	if unlimited.Reader(ctx, reader) != io.Reader(reader) || unlimited.ReaderAt(ctx, reader) != io.ReaderAt(reader) {
+		t.Error("a nil limiter wraps the reader")
+	}
+}
+
+func TestWaitNCanceled(t *testing.T) {
+	limiter, err := New(Config{Limit: "1K"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.WaitN(ctx, 10<<10); err != context.Canceled {
+		t.Errorf("WaitN() = %v, want %v", err, context.Canceled)
+	}
+}
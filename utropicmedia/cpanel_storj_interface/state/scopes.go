@@ -0,0 +1,147 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IssuedScope records a serialized scope handed out by the tool.
+// The scope itself is a secret and is not stored, only its fingerprint.
+type IssuedScope struct {
+	Fingerprint string    `json:"fingerprint"`
+	Issued      time.Time `json:"issued"`
+	// Command is the command that issued the scope, e.g. "store" or "share".
+	Command   string `json:"command"`
+	Satellite string `json:"satellite"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix"`
+
+	Restricted      bool       `json:"restricted"`
+	DisallowReads   bool       `json:"disallowReads"`
+	DisallowWrites  bool       `json:"disallowWrites"`
+	DisallowLists   bool       `json:"disallowLists"`
+	DisallowDeletes bool       `json:"disallowDeletes"`
+	NotBefore       *time.Time `json:"notBefore,omitempty"`
+	NotAfter        *time.Time `json:"notAfter,omitempty"`
+
+	// Revoked is set when the scope was marked revoked.
+	Revoked *time.Time `json:"revoked,omitempty"`
+	// RevokedOnSatellite reports whether the satellite was asked to reject the scope.
+	RevokedOnSatellite bool `json:"revokedOnSatellite,omitempty"`
+}
+
+// Expired reports whether the scope is past its NotAfter time.
+func (scope IssuedScope) Expired(now time.Time) bool {
+	return scope.NotAfter != nil && now.After(*scope.NotAfter)
+}
+
+// Status describes whether the scope is active, expired or revoked.
+func (scope IssuedScope) Status(now time.Time) string {
+	switch {
+	case scope.Revoked != nil:
+		return "revoked"
+	case scope.Expired(now):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// ScopeLog is the audit log of issued scopes, kept in a JSON file.
+type ScopeLog struct {
+	path string
+
+	mu     sync.Mutex
+	scopes []IssuedScope
+}
+
+// OpenScopeLog loads the audit log of issued scopes from path. A missing file is an empty log.
+func OpenScopeLog(path string) (*ScopeLog, error) {
+	log := &ScopeLog{path: path}
+	if err := readJSON(path, &log.scopes); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// Record appends an issued scope to the log and saves it.
+func (log *ScopeLog) Record(scope IssuedScope) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return updateJSON(log.path, &log.scopes, func() error {
+		log.scopes = append(log.scopes, scope)
+		return nil
+	})
+}
+
+// Scopes returns all issued scopes, oldest first.
+func (log *ScopeLog) Scopes() []IssuedScope {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	return append([]IssuedScope(nil), log.scopes...)
+}
+
+// Find returns the issued scope whose fingerprint starts with prefix.
+// The prefix must match exactly one scope.
+func (log *ScopeLog) Find(prefix string) (IssuedScope, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	index, err := log.findLocked(prefix)
+	if err != nil {
+		return IssuedScope{}, err
+	}
+	return log.scopes[index], nil
+}
+
+// MarkRevoked marks the issued scope whose fingerprint starts with prefix as revoked and saves the log.
+func (log *ScopeLog) MarkRevoked(prefix string, onSatellite bool, when time.Time) (IssuedScope, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	var revoked IssuedScope
+	err := updateJSON(log.path, &log.scopes, func() error {
+		index, err := log.findLocked(prefix)
+		if err != nil {
+			return err
+		}
+		log.scopes[index].Revoked = &when
+		log.scopes[index].RevokedOnSatellite = onSatellite
+		revoked = log.scopes[index]
+		return nil
+	})
+	if err != nil {
+		return IssuedScope{}, err
+	}
+	return revoked, nil
+}
+
+func (log *ScopeLog) findLocked(prefix string) (int, error) {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	if prefix == "" {
+		return -1, errors.New("no fingerprint given")
+	}
+
+	found := -1
+	for i, scope := range log.scopes {
+		if !strings.HasPrefix(scope.Fingerprint, prefix) {
+			continue
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("fingerprint %q matches more than one scope", prefix)
+		}
+		found = i
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("no scope with fingerprint %q", prefix)
+	}
+	return found, nil
+}
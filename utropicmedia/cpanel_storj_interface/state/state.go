@@ -0,0 +1,188 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"utropicmedia/cpanel_storj_interface/lock"
+)
+
+// Results of an upload.
+const (
+	ResultSuccess     = "success"
+	ResultFailed      = "failed"
+	ResultInterrupted = "interrupted"
+)
+
+// Upload records one attempt to upload a backup file.
+type Upload struct {
+	Host      string    `json:"host"`
+	Account   string    `json:"account"`
+	FileName  string    `json:"fileName"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Bucket    string    `json:"bucket"`
+	ObjectKey string    `json:"objectKey"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Succeeded reports whether the upload completed.
+func (upload Upload) Succeeded() bool {
+	return upload.Result == ResultSuccess
+}
+
+// Store keeps the upload history in a JSON file, so that later runs
+// can skip backups which were already uploaded.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	uploads []Upload
+}
+
+// Open loads the upload history from path. A missing file is an empty history.
+func Open(path string) (*Store, error) {
+	store := &Store{path: path}
+	if err := readJSON(path, &store.uploads); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Record appends an upload to the history and saves it, together with the uploads
+// other runs recorded in the file since it was opened.
+func (store *Store) Record(upload Upload) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return updateJSON(store.path, &store.uploads, func() error {
+		store.uploads = append(store.uploads, upload)
+		return nil
+	})
+}
+
+// Uploaded returns the latest successful upload of a backup file of an account,
+// matched by file name and size.
+func (store *Store) Uploaded(host, account, fileName string, size int64) (Upload, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for i := len(store.uploads) - 1; i >= 0; i-- {
+		upload := store.uploads[i]
+		if upload.Succeeded() && upload.Host == host && upload.Account == account &&
+			upload.FileName == fileName && upload.Size == size {
+			return upload, true
+		}
+	}
+	return Upload{}, false
+}
+
+// LastSuccess returns the latest successful upload of any backup of an account.
+func (store *Store) LastSuccess(host, account string) (Upload, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for i := len(store.uploads) - 1; i >= 0; i-- {
+		upload := store.uploads[i]
+		if upload.Succeeded() && upload.Host == host && upload.Account == account {
+			return upload, true
+		}
+	}
+	return Upload{}, false
+}
+
+// History returns all recorded uploads, oldest first.
+func (store *Store) History() []Upload {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return append([]Upload(nil), store.uploads...)
+}
+
+// fileLockTimeout is how long a run waits for another run writing the same file.
+const fileLockTimeout = time.Minute
+
+// fileLockPollInterval is how often a run waiting to write a file tries to lock it again.
+const fileLockPollInterval = 50 * time.Millisecond
+
+// updateJSON re-reads the file at path into v, lets change modify v and, unless it fails, writes v
+// back, holding the lock file next to it throughout. Runs sharing the file, e.g. the daemon and
+// a store run from cron, thus add their records to its latest contents instead of overwriting
+// each other's.
+func updateJSON(path string, v interface{}, change func() error) error {
+	fileLock, err := lockFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fileLock.Release() }()
+
+	if err := readJSON(path, v); err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	return writeJSON(path, v)
+}
+
+// lockFile locks the lock file of the file at path, waiting up to fileLockTimeout for another run.
+func lockFile(path string) (*lock.Lock, error) {
+	holder := lock.Holder{Command: "update " + filepath.Base(path)}
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		fileLock, err := lock.Acquire(path+".lock", holder)
+		if err == nil || !lock.IsLocked(err) || time.Now().After(deadline) {
+			return fileLock, err
+		}
+		time.Sleep(fileLockPollInterval)
+	}
+}
+
+// writeJSON writes v to a temporary file and renames it over path,
+// so an interrupted run never leaves a truncated file behind.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readJSON decodes the file at path into v. A missing file leaves v unchanged.
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
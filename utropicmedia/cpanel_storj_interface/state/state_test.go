@@ -0,0 +1,60 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRecordKeepsConcurrentRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "upload_state.json")
+
+	// Each store stands for a run that opened the history before the others recorded anything.
+	const runs, uploads = 4, 10
+	stores := make([]*Store, runs)
+	for i := range stores {
+		if stores[i], err = Open(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store *Store) {
+			defer wg.Done()
+			for n := 0; n < uploads; n++ {
+				upload := Upload{Account: fmt.Sprintf("account%d", i), FileName: fmt.Sprintf("backup%d", n), Result: ResultSuccess}
+				if err := store.Record(upload); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i, store)
+	}
+	wg.Wait()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history := store.History(); len(history) != runs*uploads {
+		t.Errorf("history has %d uploads, want %d", len(history), runs*uploads)
+	}
+	for i := 0; i < runs; i++ {
+		for n := 0; n < uploads; n++ {
+			if _, ok := store.Uploaded("", fmt.Sprintf("account%d", i), fmt.Sprintf("backup%d", n), 0); !ok {
+				t.Errorf("upload %d of run %d is missing from the history", n, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gogo/protobuf/proto"
+	"storj.io/common/macaroon"
+	"storj.io/common/pb"
+	"storj.io/uplink"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// userAgent identifies this application to the satellite.
+const userAgent = "cPanel"
+
+// uplinkConfig returns the configuration of the uplink library.
+func uplinkConfig() uplink.Config {
+	return uplink.Config{UserAgent: userAgent}
+}
+
+// access returns the access grant to open the project with.
+// With keyValue "key" it is requested from the satellite with the API key and encryption passphrase,
+// otherwise the configured access grant, or else the legacy serialized scope, is parsed.
+func (configStorj ConfigStorj) access(ctx context.Context, keyValue string) (*uplink.Access, error) {
+	if keyValue == "key" {
+		logger.Info("Requesting access grant with the API key and encryption passphrase", logging.F("satellite", configStorj.Satellite))
+		access, err := uplinkConfig().RequestAccessWithPassphrase(ctx, configStorj.Satellite, configStorj.APIKey, configStorj.EncryptionPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("could not request access grant: %v", err)
+		}
+		return access, nil
+	}
+
+	if configStorj.AccessGrant != "" {
+		access, err := uplink.ParseAccess(configStorj.AccessGrant)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse access grant: %v", err)
+		}
+		return access, nil
+	}
+
+	if configStorj.SerializedScope == "" {
+		return nil, errors.New("neither accessGrant nor serializedScope is configured, use key to derive access from the API key")
+	}
+	access, err := parseSerializedScope(configStorj.SerializedScope)
+	if err != nil {
+		return nil, err
+	}
+	// The access grant is a secret and is never logged; config migrate writes it to the configuration file.
+	logger.Warn("serializedScope is deprecated, run config migrate to replace it with accessGrant")
+	return access, nil
+}
+
+// parseSerializedScope converts a serialized scope of the former uplink library to an access grant.
+// Serialized scopes are encoded like access grants, so they can be used as such. Only scopes of
+// satellites unknown to the library, whose address lacks the node ID, cannot be converted.
+func parseSerializedScope(scope string) (*uplink.Access, error) {
+	access, err := uplink.ParseAccess(scope)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert serialized scope to an access grant, create an access grant and set accessGrant instead: %v", err)
+	}
+	return access, nil
+}
+
+// ConvertSerializedScope returns the serialized access grant to set as accessGrant
+// in place of a serialized scope of the former uplink library.
+func ConvertSerializedScope(scope string) (string, error) {
+	access, err := parseSerializedScope(scope)
+	if err != nil {
+		return "", err
+	}
+	return access.Serialize()
+}
+
+// RequestAccessGrant requests an access grant with the API key and encryption passphrase
+// of the configuration and returns it serialized, to be set as accessGrant.
+func (configStorj ConfigStorj) RequestAccessGrant(ctx context.Context) (string, error) {
+	access, err := configStorj.access(ctx, "key")
+	if err != nil {
+		return "", err
+	}
+	return access.Serialize()
+}
+
+// restrictedAccess shares access to the upload path of the configured bucket,
+// leaving out the permissions disallowed in the configuration.
+func (configStorj ConfigStorj) restrictedAccess(access *uplink.Access) (*uplink.Access, ScopeInfo, error) {
+	disallowRead, err := parseFlag("disallowReads", configStorj.DisallowReads)
+	if err != nil {
+		return nil, ScopeInfo{}, err
+	}
+	disallowWrite, err := parseFlag("disallowWrites", configStorj.DisallowWrites)
+	if err != nil {
+		return nil, ScopeInfo{}, err
+	}
+	disallowDelete, err := parseFlag("disallowDeletes", configStorj.DisallowDeletes)
+	if err != nil {
+		return nil, ScopeInfo{}, err
+	}
+	permission := uplink.Permission{
+		AllowRead:   !disallowRead,
+		AllowWrite:  !disallowWrite,
+		AllowList:   true,
+		AllowDelete: !disallowDelete,
+	}
+	prefix := strings.Trim(configStorj.UploadPath, "/")
+
+	restricted, err := access.Share(permission, uplink.SharePrefix{
+		Bucket: configStorj.Bucket,
+		Prefix: prefix,
+	})
+	if err != nil {
+		return nil, ScopeInfo{}, err
+	}
+	info, err := scopeInfo(restricted, configStorj.Bucket)
+	if err != nil {
+		return nil, ScopeInfo{}, err
+	}
+	info.restrict(permission, prefix)
+	return restricted, info, nil
+}
+
+// restrictTime limits access to the time between notBefore and notAfter, unless they are zero.
+// Permissions of the uplink library cannot express time limits yet,
+// so the caveat is added to the API key inside the access grant.
+func restrictTime(access *uplink.Access, notBefore, notAfter time.Time) (*uplink.Access, error) {
+	if notBefore.IsZero() && notAfter.IsZero() {
+		return access, nil
+	}
+
+	scope, err := decodeAccess(access)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := macaroon.ParseRawAPIKey(scope.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var caveat macaroon.Caveat
+	if !notBefore.IsZero() {
+		caveat.NotBefore = &notBefore
+	}
+	if !notAfter.IsZero() {
+		caveat.NotAfter = &notAfter
+	}
+	apiKey, err = apiKey.Restrict(caveat)
+	if err != nil {
+		return nil, err
+	}
+	scope.ApiKey = apiKey.SerializeRaw()
+
+	data, err := proto.Marshal(scope)
+	if err != nil {
+		return nil, err
+	}
+	return uplink.ParseAccess(base58.CheckEncode(data, 0))
+}
+
+// scopeInfo describes an unrestricted access grant for bucket.
+func scopeInfo(access *uplink.Access, bucket string) (ScopeInfo, error) {
+	scope, err := decodeAccess(access)
+	if err != nil {
+		return ScopeInfo{}, err
+	}
+	return ScopeInfo{
+		Satellite: scope.SatelliteAddr,
+		Bucket:    bucket,
+	}, nil
+}
+
+// decodeAccess returns the serialized fields of an access grant,
+// which the uplink library does not expose.
+func decodeAccess(access *uplink.Access) (*pb.Scope, error) {
+	serialized, err := access.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	data, version, err := base58.CheckDecode(serialized)
+	if err != nil || version != 0 {
+		return nil, errors.New("invalid access grant format")
+	}
+	scope := new(pb.Scope)
+	if err := proto.Unmarshal(data, scope); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
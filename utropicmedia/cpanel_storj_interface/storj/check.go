@@ -0,0 +1,176 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"storj.io/common/macaroon"
+	"storj.io/uplink"
+
+	"utropicmedia/cpanel_storj_interface/ratelimit"
+)
+
+// ConfigError is a problem with a field of the Storj configuration.
+type ConfigError struct {
+	// Field is the JSON name of the field, e.g. "disallowReads".
+	Field   string
+	Message string
+}
+
+func (err ConfigError) Error() string {
+	return err.Field + ": " + err.Message
+}
+
+// Check validates the configuration without connecting to the satellite
+// and returns every problem found.
+func (configStorj ConfigStorj) Check() []ConfigError {
+	var problems []ConfigError
+	report := func(field, format string, args ...interface{}) {
+		problems = append(problems, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if configStorj.Bucket == "" {
+		report("bucketName", "is required")
+	} else if err := checkBucketName(configStorj.Bucket); err != nil {
+		report("bucketName", "%v", err)
+	}
+
+	if configStorj.AccessGrant == "" && configStorj.SerializedScope == "" &&
+		(configStorj.APIKey == "" || configStorj.Satellite == "" || configStorj.EncryptionPassphrase == "") {
+		report("accessGrant", "set accessGrant, or apikey, satelliteURL and encryptionpassphrase")
+	}
+	if configStorj.AccessGrant != "" {
+		if _, err := uplink.ParseAccess(configStorj.AccessGrant); err != nil {
+			report("accessGrant", "is not a valid access grant: %v", err)
+		}
+	}
+	if configStorj.SerializedScope != "" {
+		if _, err := uplink.ParseAccess(configStorj.SerializedScope); err != nil {
+			report("serializedScope", "cannot be converted to an access grant: %v", err)
+		}
+	}
+	if configStorj.APIKey != "" {
+		if _, err := macaroon.ParseAPIKey(configStorj.APIKey); err != nil {
+			report("apikey", "is not a valid API key: %v", err)
+		}
+	}
+	if configStorj.Satellite != "" {
+		if err := checkSatellite(configStorj.Satellite); err != nil {
+			report("satelliteURL", "%v", err)
+		}
+	}
+
+	for _, flag := range []struct{ field, value string }{
+		{"disallowReads", configStorj.DisallowReads},
+		{"disallowWrites", configStorj.DisallowWrites},
+		{"disallowDeletes", configStorj.DisallowDeletes},
+	} {
+		if _, err := parseFlag(flag.field, flag.value); err != nil {
+			report(flag.field, "expected \"true\" or \"false\", found %q", flag.value)
+		}
+	}
+
+	if err := configStorj.CheckKeyTemplate(); err != nil {
+		report("keyTemplate", "%v", err)
+	}
+	if configStorj.PartSizeMB < 0 {
+		report("partSizeMB", "must not be negative")
+	}
+	if configStorj.UploadConcurrency < 0 {
+		report("uploadConcurrency", "must not be negative")
+	}
+	if _, err := ratelimit.New(configStorj.Bandwidth); err != nil {
+		report("bandwidth", "%v", err)
+	}
+	return problems
+}
+
+// parseFlag parses one of the disallow fields, which are strings holding "true" or "false".
+// An empty value is false.
+func parseFlag(field, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: expected \"true\" or \"false\", found %q", field, value)
+	}
+	return flag, nil
+}
+
+// checkSatellite verifies that address has the form [nodeid@]host:port.
+func checkSatellite(address string) error {
+	hostPort := address
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		if i == 0 {
+			return fmt.Errorf("satellite address %q has an empty node ID", address)
+		}
+		hostPort = address[i+1:]
+	}
+	if strings.Contains(hostPort, "://") {
+		return fmt.Errorf("satellite address %q must not contain a scheme, expected host:port", address)
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return fmt.Errorf("satellite address %q is not of the form host:port", address)
+	}
+	if host == "" {
+		return fmt.Errorf("satellite address %q has no host", address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("satellite address %q has an invalid port", address)
+	}
+	return nil
+}
+
+// checkBucketName verifies that name is a valid bucket name.
+func checkBucketName(name string) error {
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Errorf("bucket name %q must be 3 to 63 characters long", name)
+	}
+	if bucketName(name) != name {
+		return fmt.Errorf("bucket name %q may only contain lowercase letters, digits, dashes and dots, and must start and end with a letter or digit", name)
+	}
+	return nil
+}
+
+// CheckConnection opens the project with the access of the configuration,
+// as OpenSession would with keyValue, and checks that the bucket can be listed.
+// It reports whether the bucket exists; a missing bucket is created by the first upload.
+func CheckConnection(ctx context.Context, configStorj ConfigStorj, keyValue string) (bucketExists bool, err error) {
+	if configStorj.BucketPerAccount {
+		return false, fmt.Errorf("bucketPerAccount is set, but no account was selected for bucket %q", configStorj.Bucket)
+	}
+
+	access, err := configStorj.access(ctx, keyValue)
+	if err != nil {
+		return false, err
+	}
+	project, err := uplinkConfig().OpenProject(ctx, access)
+	if err != nil {
+		return false, fmt.Errorf("could not open project: %v", err)
+	}
+	defer project.Close()
+
+	if _, err := project.StatBucket(ctx, configStorj.Bucket); err != nil {
+		if uplink.ErrBucketNotFound.Has(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not access bucket %q: %v", configStorj.Bucket, err)
+	}
+
+	objects := project.ListObjects(ctx, configStorj.Bucket, &uplink.ListObjectsOptions{
+		Prefix: configStorj.uploadPrefix(),
+	})
+	objects.Next()
+	if err := objects.Err(); err != nil {
+		return true, fmt.Errorf("could not list bucket %q: %v", configStorj.Bucket, err)
+	}
+	return true, nil
+}
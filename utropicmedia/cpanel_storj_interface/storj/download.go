@@ -0,0 +1,234 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"storj.io/uplink"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// ChecksumError is returned when the SHA-256 hash of a downloaded object differs from the hash stored with it.
+type ChecksumError struct {
+	Key      string
+	Expected string
+	Actual   string
+}
+
+func (err *ChecksumError) Error() string {
+	return fmt.Sprintf("sha256 of %s is %s, expected %s", err.Key, err.Actual, err.Expected)
+}
+
+// DownloadResult describes a downloaded object.
+type DownloadResult struct {
+	Key string
+	// Size is the size of the object, -1 when it is not stored with the object.
+	Size int64
+	// Resumed is the number of bytes taken from an earlier, interrupted download.
+	Resumed int64
+	// Bytes is the number of bytes downloaded.
+	Bytes  int64
+	SHA256 string
+	// Verified reports whether SHA256 matched the hash stored with the object.
+	// Objects uploaded before hashes were stored cannot be verified.
+	Verified bool
+	Duration time.Duration
+}
+
+// DownloadInfo describes the object DownloadObject would download.
+type DownloadInfo struct {
+	Key string
+	// Size is the size of the object, -1 when it is not stored with the object.
+	Size int64
+	// Parts is the number of parts of an object uploaded in parts, 0 otherwise.
+	Parts int
+	// SHA256 is the hash the download is verified against, empty when none is stored with the object.
+	SHA256 string
+}
+
+// objectRange is a part of an object stored as an object of its own.
+type objectRange struct {
+	key    string
+	offset int64
+	// length is -1 when the size of the object is not known.
+	length int64
+}
+
+// LatestBackup returns the most recent backup of a cPanel account in the bucket:
+// the one generated last according to its key, or uploaded last when the key has no date.
+// Objects other than the backups of the account are left out, see AccountBackup.
+func (session *Session) LatestBackup(ctx context.Context, host, account string) (Object, error) {
+	objects, err := session.List(ctx, session.Config.AccountPrefix(host, account))
+	if err != nil {
+		return Object{}, err
+	}
+
+	var latest Object
+	var latestTime time.Time
+	for _, object := range objects {
+		fields, ok := session.Config.AccountBackup(object.Key, host, account)
+		if !ok {
+			continue
+		}
+		backupTime := fields.Time
+		if backupTime.IsZero() {
+			backupTime = object.Created
+		}
+		if latest.Key == "" || backupTime.After(latestTime) {
+			latest, latestTime = object, backupTime
+		}
+	}
+	if latest.Key == "" {
+		return Object{}, fmt.Errorf("no backup of %s@%s in bucket %q", account, host, session.Config.Bucket)
+	}
+	return latest, nil
+}
+
+// DownloadObject writes the object with the given key to w and verifies its SHA-256 hash against
+// the hash stored with the object, if any. An object uploaded in parts is put together from the
+// parts listed by its manifest.
+// To resume an interrupted download, partial reads the bytes it wrote. They are hashed, and only
+// the rest of the object is downloaded with range requests. partial may be nil.
+func (session *Session) DownloadObject(ctx context.Context, key string, w io.Writer, partial io.Reader) (DownloadResult, error) {
+	result := DownloadResult{Key: key, Size: -1}
+	log := session.log.With(logging.F("key", key))
+
+	info, ranges, err := session.downloadSource(ctx, key)
+	if err != nil {
+		return result, err
+	}
+	result.Size = info.Size
+	expected := info.SHA256
+
+	hash := sha256.New()
+	if partial != nil {
+		result.Resumed, err = io.Copy(hash, partial)
+		if err != nil {
+			return result, err
+		}
+		if result.Size >= 0 && result.Resumed > result.Size {
+			return result, fmt.Errorf("the partial download of %s has %d bytes, more than the %d bytes of the object", key, result.Resumed, result.Size)
+		}
+	}
+
+	log.Info("Downloading object", logging.F("size", result.Size), logging.F("resumeAt", result.Resumed))
+	start := time.Now()
+	writer := io.MultiWriter(w, hash)
+	for _, part := range ranges {
+		if part.length >= 0 && part.offset+part.length <= result.Resumed {
+			continue
+		}
+		offset := result.Resumed - part.offset
+		if offset < 0 {
+			offset = 0
+		}
+		n, err := session.downloadRange(ctx, part.key, writer, offset)
+		result.Bytes += n
+		if err != nil {
+			result.Duration = time.Since(start)
+			log.Error("Could not download object", logging.F("error", err), logging.F("bytes", result.Bytes))
+			return result, err
+		}
+	}
+	result.Duration = time.Since(start)
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if total := result.Resumed + result.Bytes; result.Size >= 0 && total != result.Size {
+		return result, fmt.Errorf("downloaded %d bytes of %s, expected %d", total, key, result.Size)
+	}
+	switch {
+	case expected == "":
+		log.Warn("No hash is stored with the object, the download is not verified")
+	case expected != result.SHA256:
+		return result, &ChecksumError{Key: key, Expected: expected, Actual: result.SHA256}
+	default:
+		result.Verified = true
+	}
+
+	log.Info("Downloaded object",
+		logging.F("bytes", result.Bytes),
+		logging.F("sha256", result.SHA256),
+		logging.F("verified", result.Verified),
+		logging.F("duration", result.Duration))
+	return result, nil
+}
+
+// StatDownload returns what DownloadObject would download for the given key, without downloading it.
+// The manifest of an object uploaded in parts is read to find its size and hash.
+func (session *Session) StatDownload(ctx context.Context, key string) (DownloadInfo, error) {
+	info, _, err := session.downloadSource(ctx, key)
+	return info, err
+}
+
+// downloadSource describes the object with the given key and where its parts belong.
+func (session *Session) downloadSource(ctx context.Context, key string) (DownloadInfo, []objectRange, error) {
+	info := DownloadInfo{Key: key, Size: -1}
+	object, err := session.project.StatObject(ctx, session.Config.Bucket, key)
+	if err != nil {
+		return info, nil, err
+	}
+	if parts, _ := strconv.Atoi(object.Custom[metadataParts]); parts > 0 {
+		manifest, err := session.readManifest(ctx, key)
+		if err != nil {
+			return info, nil, err
+		}
+		info.Size, info.Parts, info.SHA256 = manifest.Size, len(manifest.Parts), manifest.SHA256
+		return info, manifest.ranges(), nil
+	}
+	info.SHA256 = object.Custom[metadataSHA256]
+	if size, err := strconv.ParseInt(object.Custom[metadataSize], 10, 64); err == nil {
+		info.Size = size
+	}
+	return info, []objectRange{{key: key, length: info.Size}}, nil
+}
+
+// downloadRange writes the object with the given key to w, starting at offset.
+func (session *Session) downloadRange(ctx context.Context, key string, w io.Writer, offset int64) (int64, error) {
+	download, err := session.project.DownloadObject(ctx, session.Config.Bucket, key, &uplink.DownloadOptions{
+		Offset: offset,
+		Length: -1,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = download.Close() }()
+	return io.Copy(w, session.limiter.Reader(ctx, download))
+}
+
+// readManifest downloads the manifest stored as the object of a parted upload.
+func (session *Session) readManifest(ctx context.Context, key string) (Manifest, error) {
+	var manifest Manifest
+	download, err := session.project.DownloadObject(ctx, session.Config.Bucket, key, nil)
+	if err != nil {
+		return manifest, err
+	}
+	defer func() { _ = download.Close() }()
+	if err := json.NewDecoder(download).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("could not read the manifest of %s: %v", key, err)
+	}
+	return manifest, nil
+}
+
+// ranges returns where the parts of the object described by the manifest belong.
+func (manifest Manifest) ranges() []objectRange {
+	ranges := make([]objectRange, len(manifest.Parts))
+	for i, key := range manifest.Parts {
+		offset := int64(i) * manifest.PartSize
+		length := manifest.PartSize
+		if offset+length > manifest.Size {
+			length = manifest.Size - offset
+		}
+		ranges[i] = objectRange{key: key, offset: offset, length: length}
+	}
+	return ranges
+}
@@ -0,0 +1,253 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultKeyTemplate stores backups directly below the upload path under their file name.
+const DefaultKeyTemplate = "{filename}"
+
+// BackupTypeFull is the {type} of full cPanel backups.
+const BackupTypeFull = "full"
+
+// KeyFields are the values substituted into the key template.
+type KeyFields struct {
+	Host     string
+	Account  string
+	Type     string
+	FileName string
+	// Time is when the backup was generated, used for {yyyy}, {mm} and {dd}.
+	Time time.Time
+}
+
+// keyPlaceholders maps the placeholders of a key template
+// to the pattern matching their value when parsing keys.
+var keyPlaceholders = map[string]string{
+	"{host}":     `(?P<host>[^/]+)`,
+	"{account}":  `(?P<account>[^/]+)`,
+	"{type}":     `(?P<type>[^/]+)`,
+	"{yyyy}":     `(?P<yyyy>\d{4})`,
+	"{mm}":       `(?P<mm>\d{2})`,
+	"{dd}":       `(?P<dd>\d{2})`,
+	"{filename}": `(?P<filename>[^/]+)`,
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// keyTemplate returns the configured key template or the default one.
+func (configStorj ConfigStorj) keyTemplate() string {
+	if configStorj.KeyTemplate == "" {
+		return DefaultKeyTemplate
+	}
+	return strings.Trim(configStorj.KeyTemplate, "/")
+}
+
+// CheckKeyTemplate verifies that the key template only uses known placeholders
+// and contains {filename}, so that every backup gets its own key.
+func (configStorj ConfigStorj) CheckKeyTemplate() error {
+	template := configStorj.keyTemplate()
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if _, ok := keyPlaceholders[placeholder]; !ok {
+			return fmt.Errorf("unknown placeholder %s in key template %q", placeholder, template)
+		}
+	}
+	if !strings.Contains(template, "{filename}") {
+		return fmt.Errorf("key template %q must contain {filename}", template)
+	}
+	return nil
+}
+
+// uploadPrefix returns the configured upload path with a trailing slash,
+// or an empty prefix when objects are stored at the root of the bucket.
+func (configStorj ConfigStorj) uploadPrefix() string {
+	prefix := strings.Trim(configStorj.UploadPath, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// ObjectKey returns the key of a backup: the upload path followed by the expanded key template.
+// Path segments that expand to nothing are left out.
+func (configStorj ConfigStorj) ObjectKey(fields KeyFields) string {
+	values := map[string]string{
+		"{host}":     fields.Host,
+		"{account}":  fields.Account,
+		"{type}":     fields.Type,
+		"{filename}": fields.FileName,
+	}
+	if !fields.Time.IsZero() {
+		t := fields.Time.UTC()
+		values["{yyyy}"] = fmt.Sprintf("%04d", t.Year())
+		values["{mm}"] = fmt.Sprintf("%02d", int(t.Month()))
+		values["{dd}"] = fmt.Sprintf("%02d", t.Day())
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(configStorj.keyTemplate(), "/") {
+		expanded := placeholderPattern.ReplaceAllStringFunc(segment, func(placeholder string) string {
+			return strings.Replace(values[placeholder], "/", "_", -1)
+		})
+		if expanded != "" {
+			segments = append(segments, expanded)
+		}
+	}
+	return configStorj.uploadPrefix() + strings.Join(segments, "/")
+}
+
+// AccountPrefix returns the longest key prefix shared by all backups of an account,
+// i.e. the upload path and the key template up to the first placeholder
+// that does not depend on the host or account only.
+func (configStorj ConfigStorj) AccountPrefix(host, account string) string {
+	var segments []string
+	for _, segment := range strings.Split(configStorj.keyTemplate(), "/") {
+		static := true
+		expanded := placeholderPattern.ReplaceAllStringFunc(segment, func(placeholder string) string {
+			switch placeholder {
+			case "{host}":
+				return host
+			case "{account}":
+				return account
+			}
+			static = false
+			return ""
+		})
+		if !static {
+			break
+		}
+		if expanded != "" {
+			segments = append(segments, expanded)
+		}
+	}
+
+	prefix := configStorj.uploadPrefix()
+	if len(segments) > 0 {
+		prefix += strings.Join(segments, "/") + "/"
+	}
+	return prefix
+}
+
+// ParseObjectKey extracts the key fields from a key created with ObjectKey.
+// It reports false when the key does not match the key template.
+func (configStorj ConfigStorj) ParseObjectKey(key string) (KeyFields, bool) {
+	pattern := regexp.QuoteMeta(configStorj.uploadPrefix())
+	var segments []string
+	for _, segment := range strings.Split(configStorj.keyTemplate(), "/") {
+		var segmentPattern string
+		rest := segment
+		for {
+			loc := placeholderPattern.FindStringIndex(rest)
+			if loc == nil {
+				segmentPattern += regexp.QuoteMeta(rest)
+				break
+			}
+			segmentPattern += regexp.QuoteMeta(rest[:loc[0]]) + keyPlaceholders[rest[loc[0]:loc[1]]]
+			rest = rest[loc[1]:]
+		}
+		segments = append(segments, segmentPattern)
+	}
+	pattern += strings.Join(segments, "/")
+
+	re, err := regexp.Compile("^" + pattern + "$")
+	if err != nil {
+		return KeyFields{}, false
+	}
+	match := re.FindStringSubmatch(key)
+	if match == nil {
+		return KeyFields{}, false
+	}
+
+	var fields KeyFields
+	var year, month, day int
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "host":
+			fields.Host = match[i]
+		case "account":
+			fields.Account = match[i]
+		case "type":
+			fields.Type = match[i]
+		case "filename":
+			fields.FileName = match[i]
+		case "yyyy":
+			year, _ = strconv.Atoi(match[i])
+		case "mm":
+			month, _ = strconv.Atoi(match[i])
+		case "dd":
+			day, _ = strconv.Atoi(match[i])
+		}
+	}
+	if year > 0 {
+		if month == 0 {
+			month = 1
+		}
+		if day == 0 {
+			day = 1
+		}
+		fields.Time = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+	return fields, true
+}
+
+// backupFileName matches the names cPanel gives full backups, e.g. backup-2.27.2020_10-00-00_username.tar.gz,
+// capturing the account.
+var backupFileName = regexp.MustCompile(`^backup-\d{1,2}\.\d{1,2}\.\d{4}_\d{2}-\d{2}-\d{2}_(.+)\.tar\.gz$`)
+
+// AccountBackup extracts the key fields from the key of a backup of the cPanel account on host.
+// It reports false for keys not matching the key template, for objects that are no cPanel backups
+// and for the backups of other accounts, which the default template, lacking {account},
+// stores next to each other in a shared bucket.
+func (configStorj ConfigStorj) AccountBackup(key, host, account string) (KeyFields, bool) {
+	fields, ok := configStorj.ParseObjectKey(key)
+	if !ok {
+		return KeyFields{}, false
+	}
+	match := backupFileName.FindStringSubmatch(fields.FileName)
+	if match == nil || match[1] != account {
+		return KeyFields{}, false
+	}
+	if fields.Account != "" && fields.Account != account {
+		return KeyFields{}, false
+	}
+	if fields.Host != "" && fields.Host != host {
+		return KeyFields{}, false
+	}
+	return fields, true
+}
+
+// ForAccount returns the configuration to use for the backups of an account.
+// With BucketPerAccount the account name is appended to the bucket name.
+func (configStorj ConfigStorj) ForAccount(account string) ConfigStorj {
+	if !configStorj.BucketPerAccount || account == "" {
+		return configStorj
+	}
+	configStorj.Bucket = bucketName(configStorj.Bucket + "-" + account)
+	configStorj.BucketPerAccount = false
+	return configStorj
+}
+
+// bucketName turns name into a valid bucket name:
+// lowercase letters, digits and dashes, at most 63 characters.
+func bucketName(name string) string {
+	name = strings.ToLower(name)
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	name = strings.Trim(b.String(), "-.")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-.")
+	}
+	return name
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"testing"
+	"time"
+)
+
+var backupTime = time.Date(2020, 2, 27, 10, 0, 0, 0, time.UTC)
+
+func TestObjectKey(t *testing.T) {
+	fields := KeyFields{
+		Host:     "cpanel.example.com",
+		Account:  "alice",
+		Type:     BackupTypeFull,
+		FileName: "backup-2.27.2020_10-00-00_alice.tar.gz",
+		Time:     backupTime,
+	}
+	tests := []struct {
+		name   string
+		config ConfigStorj
+		fields KeyFields
+		key    string
+	}{
+		{"default template", ConfigStorj{}, fields, "backup-2.27.2020_10-00-00_alice.tar.gz"},
+		{"upload path", ConfigStorj{UploadPath: "/backups/"}, fields, "backups/backup-2.27.2020_10-00-00_alice.tar.gz"},
+		{
+			"all placeholders",
+			ConfigStorj{KeyTemplate: "/{host}/{account}/{type}/{yyyy}/{mm}/{dd}/{filename}/"},
+			fields,
+			"cpanel.example.com/alice/full/2020/02/27/backup-2.27.2020_10-00-00_alice.tar.gz",
+		},
+		{
+			"empty segments are left out",
+			ConfigStorj{KeyTemplate: "{host}/{yyyy}/{filename}"},
+			KeyFields{FileName: "notes.txt"},
+			"notes.txt",
+		},
+		{
+			"slashes in values are replaced",
+			ConfigStorj{KeyTemplate: "{account}/{filename}"},
+			KeyFields{Account: "a/b", FileName: "notes.txt"},
+			"a_b/notes.txt",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if key := test.config.ObjectKey(test.fields); key != test.key {
+				t.Errorf("ObjectKey() = %q, want %q", key, test.key)
+			}
+		})
+	}
+}
+
+func TestAccountPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ConfigStorj
+		prefix string
+	}{
+		{"default template", ConfigStorj{}, ""},
+		{"upload path", ConfigStorj{UploadPath: "backups"}, "backups/"},
+		{"account", ConfigStorj{KeyTemplate: "{account}/{filename}"}, "alice/"},
+		{"host and account", ConfigStorj{UploadPath: "b", KeyTemplate: "{host}/{account}/{yyyy}/{filename}"}, "b/cpanel.example.com/alice/"},
+		{"date first", ConfigStorj{KeyTemplate: "{yyyy}/{account}/{filename}"}, ""},
+		{"account within a segment", ConfigStorj{KeyTemplate: "{account}-{type}/{filename}"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if prefix := test.config.AccountPrefix("cpanel.example.com", "alice"); prefix != test.prefix {
+				t.Errorf("AccountPrefix() = %q, want %q", prefix, test.prefix)
+			}
+		})
+	}
+}
+
+func TestParseObjectKey(t *testing.T) {
+	dated := ConfigStorj{UploadPath: "backups", KeyTemplate: "{host}/{account}/{yyyy}/{mm}/{dd}/{filename}"}
+	tests := []struct {
+		name   string
+		config ConfigStorj
+		key    string
+		fields KeyFields
+		ok     bool
+	}{
+		{
+			"default template",
+			ConfigStorj{},
+			"backup-2.27.2020_10-00-00_alice.tar.gz",
+			KeyFields{FileName: "backup-2.27.2020_10-00-00_alice.tar.gz"},
+			true,
+		},
+		{"default template with a directory", ConfigStorj{}, "other/file.tar.gz", KeyFields{}, false},
+		{
+			"all fields",
+			dated,
+			"backups/cpanel.example.com/alice/2020/02/27/backup-2.27.2020_10-00-00_alice.tar.gz",
+			KeyFields{
+				Host:     "cpanel.example.com",
+				Account:  "alice",
+				FileName: "backup-2.27.2020_10-00-00_alice.tar.gz",
+				Time:     time.Date(2020, 2, 27, 0, 0, 0, 0, time.UTC),
+			},
+			true,
+		},
+		{
+			"year only",
+			ConfigStorj{KeyTemplate: "{yyyy}/{filename}"},
+			"2020/notes.txt",
+			KeyFields{FileName: "notes.txt", Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			true,
+		},
+		{"other upload path", dated, "archive/cpanel.example.com/alice/2020/02/27/a.tar.gz", KeyFields{}, false},
+		{"missing segment", dated, "backups/cpanel.example.com/alice/2020/02/a.tar.gz", KeyFields{}, false},
+		{"invalid year", dated, "backups/cpanel.example.com/alice/20/02/27/a.tar.gz", KeyFields{}, false},
+		{"part of a parted object", ConfigStorj{}, PartKey("a.tar.gz", 1), KeyFields{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, ok := test.config.ParseObjectKey(test.key)
+			if ok != test.ok || fields != test.fields {
+				t.Errorf("ParseObjectKey(%q) = %+v, %v, want %+v, %v", test.key, fields, ok, test.fields, test.ok)
+			}
+		})
+	}
+}
+
+func TestParseObjectKeyRoundTrip(t *testing.T) {
+	config := ConfigStorj{UploadPath: "backups", KeyTemplate: "{host}/{account}/{type}/{yyyy}-{mm}-{dd}/{filename}"}
+	fields := KeyFields{
+		Host:     "cpanel.example.com",
+		Account:  "alice",
+		Type:     BackupTypeFull,
+		FileName: "backup-2.27.2020_10-00-00_alice.tar.gz",
+		Time:     time.Date(2020, 2, 27, 0, 0, 0, 0, time.UTC),
+	}
+	parsed, ok := config.ParseObjectKey(config.ObjectKey(fields))
+	if !ok || parsed != fields {
+		t.Errorf("ParseObjectKey(ObjectKey(%+v)) = %+v, %v", fields, parsed, ok)
+	}
+}
+
+func TestCheckKeyTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"", true},
+		{"{filename}", true},
+		{"/{host}/{account}/{type}/{yyyy}/{mm}/{dd}/{filename}/", true},
+		{"{account}-{yyyy}{mm}{dd}-{filename}", true},
+		{"{account}/{yyyy}", false},
+		{"{user}/{filename}", false},
+		{"{Filename}", false},
+	}
+	for _, test := range tests {
+		err := ConfigStorj{KeyTemplate: test.template}.CheckKeyTemplate()
+		if (err == nil) != test.valid {
+			t.Errorf("CheckKeyTemplate() of %q = %v, want valid %v", test.template, err, test.valid)
+		}
+	}
+}
+
+func TestAccountBackup(t *testing.T) {
+	const host = "cpanel.example.com"
+	shared := ConfigStorj{UploadPath: "backups"}
+	perAccount := ConfigStorj{KeyTemplate: "{host}/{account}/{filename}"}
+	tests := []struct {
+		name   string
+		config ConfigStorj
+		key    string
+		ok     bool
+	}{
+		{"own backup in a shared bucket", shared, "backups/backup-2.27.2020_10-00-00_alice.tar.gz", true},
+		{"backup of another account in a shared bucket", shared, "backups/backup-2.27.2020_10-00-00_bob.tar.gz", false},
+		{"account sharing a suffix", shared, "backups/backup-2.27.2020_10-00-00_malice.tar.gz", false},
+		{"account containing the account", shared, "backups/backup-2.27.2020_10-00-00_alice_old.tar.gz", false},
+		{"other file", shared, "backups/test.txt", false},
+		{"other tarball", shared, "backups/alice.tar.gz", false},
+		{"outside the upload path", shared, "backup-2.27.2020_10-00-00_alice.tar.gz", false},
+		{"own backup below the account", perAccount, host + "/alice/backup-2.27.2020_10-00-00_alice.tar.gz", true},
+		{"backup below another account", perAccount, host + "/bob/backup-2.27.2020_10-00-00_alice.tar.gz", false},
+		{"backup of another account below the account", perAccount, host + "/alice/backup-2.27.2020_10-00-00_bob.tar.gz", false},
+		{"backup of another host", perAccount, "other.example.com/alice/backup-2.27.2020_10-00-00_alice.tar.gz", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := test.config.AccountBackup(test.key, host, "alice"); ok != test.ok {
+				t.Errorf("AccountBackup(%q) = %v, want %v", test.key, ok, test.ok)
+			}
+		})
+	}
+}
+
+func TestForAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ConfigStorj
+		account string
+		bucket  string
+	}{
+		{"shared bucket", ConfigStorj{Bucket: "backups"}, "alice", "backups"},
+		{"bucket per account", ConfigStorj{Bucket: "backups", BucketPerAccount: true}, "alice", "backups-alice"},
+		{"without account", ConfigStorj{Bucket: "backups", BucketPerAccount: true}, "", "backups"},
+		{"invalid characters", ConfigStorj{Bucket: "Backups", BucketPerAccount: true}, "Alice_B", "backups-alice-b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if bucket := test.config.ForAccount(test.account).Bucket; bucket != test.bucket {
+				t.Errorf("ForAccount(%q).Bucket = %q, want %q", test.account, bucket, test.bucket)
+			}
+		})
+	}
+}
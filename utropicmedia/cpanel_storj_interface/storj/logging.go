@@ -0,0 +1,17 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// logger receives the progress messages of the package.
+var logger = logging.Default()
+
+// SetLogger replaces the logger of the package, e.g. to add fields identifying the job
+// or to write JSON.
+func SetLogger(l *logging.Logger) {
+	logger = l
+}
@@ -0,0 +1,276 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"storj.io/uplink"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+)
+
+// DefaultUploadConcurrency is the number of parts uploaded at the same time
+// when UploadConcurrency is not configured.
+const DefaultUploadConcurrency = 4
+
+// partAttempts is how often the upload of a single part is tried before giving up.
+const partAttempts = 3
+
+// Metadata fields of the manifest object of a parted upload; size and hash are stored with other objects as well.
+const (
+	metadataParts    = "cpanel-storj-parts"
+	metadataSize     = "cpanel-storj-size"
+	metadataPartSize = "cpanel-storj-part-size"
+	metadataSHA256   = "cpanel-storj-sha256"
+)
+
+// partsInfix separates the key of a parted object from the keys of its parts.
+const partsInfix = ".parts/"
+
+// Manifest describes an object uploaded in parts. It is stored as the object itself,
+// while the parts are stored below the object key followed by ".parts/" and a five digit
+// part number counting from 1. Other clients see the manifest rather than the backup;
+// DownloadObject puts the backup together again, as does joining the parts in order.
+type Manifest struct {
+	Size     int64    `json:"size"`
+	PartSize int64    `json:"partSize"`
+	SHA256   string   `json:"sha256"`
+	Parts    []string `json:"parts"`
+}
+
+// fileSource is a seekable, file-backed upload source such as an *os.File.
+type fileSource interface {
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
+
+// PartKey returns the key of part n (counting from 1) of the object with the given key.
+func PartKey(key string, n int) string {
+	return fmt.Sprintf("%s%s%05d", key, partsInfix, n)
+}
+
+// IsPartKey reports whether key belongs to a part of a parted object.
+func IsPartKey(key string) bool {
+	return strings.Contains(key, partsInfix)
+}
+
+// partSize returns the configured part size in bytes, 0 when parted uploads are disabled.
+func (configStorj ConfigStorj) partSize() int64 {
+	if configStorj.PartSizeMB <= 0 {
+		return 0
+	}
+	return int64(configStorj.PartSizeMB) << 20
+}
+
+// uploadConcurrency returns the configured number of parallel part uploads or the default.
+func (configStorj ConfigStorj) uploadConcurrency() int {
+	if configStorj.UploadConcurrency <= 0 {
+		return DefaultUploadConcurrency
+	}
+	return configStorj.UploadConcurrency
+}
+
+// partedSource returns the size of fileReader when it should be uploaded in parts:
+// parted uploads are enabled and fileReader is a regular file larger than one part.
+func (session *Session) partedSource(fileReader io.Reader) (fileSource, int64, bool) {
+	partSize := session.Config.partSize()
+	file, ok := fileReader.(fileSource)
+	if partSize == 0 || !ok {
+		return nil, 0, false
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() <= partSize {
+		return nil, 0, false
+	}
+	return file, info.Size(), true
+}
+
+// uploadParts uploads the whole file in parts of PartSizeMB, UploadConcurrency at a time,
+// and then the manifest under key. Parts that were uploaded are deleted again when the upload fails.
+// The file is read once, in order, into a buffer per part being uploaded, so that the hash of the
+// whole file is computed while it is uploaded and failed attempts are retried from memory.
+func (session *Session) uploadParts(ctx context.Context, key string, source io.ReaderAt, size int64) (UploadResult, error) {
+	result := UploadResult{Key: key}
+	partSize := session.Config.partSize()
+	count := int((size + partSize - 1) / partSize)
+	concurrency := session.Config.uploadConcurrency()
+	if concurrency > count {
+		concurrency = count
+	}
+
+	log := session.log.With(logging.F("key", key))
+	log.Info("Uploading object in parts", logging.F("parts", count), logging.F("concurrency", concurrency))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+
+	manifest := Manifest{
+		Size:     size,
+		PartSize: partSize,
+		Parts:    make([]string, count),
+	}
+
+	// A buffer is reused for the next part once its part is uploaded.
+	buffers := make(chan []byte, concurrency)
+	for w := 0; w < concurrency; w++ {
+		buffers <- make([]byte, partSize)
+	}
+
+	type part struct {
+		index int
+		data  []byte
+	}
+	jobs := make(chan part)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				partKey := PartKey(key, job.index+1)
+				err := session.uploadPart(ctx, partKey, job.data)
+				buffers <- job.data
+				if err != nil {
+					errs <- fmt.Errorf("part %d of %d: %v", job.index+1, count, err)
+					cancel()
+					return
+				}
+				manifest.Parts[job.index] = partKey
+				log.Info("Uploaded part", logging.F("part", job.index+1), logging.F("parts", count))
+			}
+		}()
+	}
+
+	hash := sha256.New()
+	var readErr error
+feed:
+	for i := 0; i < count; i++ {
+		var buffer []byte
+		select {
+		case buffer = <-buffers:
+		case <-ctx.Done():
+			break feed
+		}
+		offset := int64(i) * partSize
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		buffer = buffer[:length]
+		if _, err := io.ReadFull(io.NewSectionReader(source, offset, length), buffer); err != nil {
+			readErr = fmt.Errorf("could not read part %d of %d: %v", i+1, count, err)
+			cancel()
+			break
+		}
+		_, _ = hash.Write(buffer)
+		select {
+		case jobs <- part{index: i, data: buffer}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var err error
+	select {
+	case err = <-errs:
+	default:
+		err = readErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		err = session.uploadManifest(ctx, key, manifest)
+	}
+	result.Duration = time.Since(start)
+	if err != nil {
+		log.Error("Could not upload object", logging.F("error", err), logging.F("duration", result.Duration))
+		session.deleteParts(manifest.Parts)
+		return result, err
+	}
+	result.Bytes = size
+	result.SHA256 = manifest.SHA256
+
+	log.Info("Uploaded object",
+		logging.F("bytes", result.Bytes),
+		logging.F("parts", count),
+		logging.F("sha256", result.SHA256),
+		logging.F("duration", result.Duration))
+	return result, nil
+}
+
+// uploadPart uploads the data of a part, retrying failed attempts.
+func (session *Session) uploadPart(ctx context.Context, key string, data []byte) error {
+	var err error
+	for attempt := 1; attempt <= partAttempts; attempt++ {
+		part := session.limiter.Reader(ctx, bytes.NewReader(data))
+		err = session.putObject(ctx, key, part, nil)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// uploadManifest stores the manifest of a parted object under its key.
+// The metadata allows listing the object with its real size without downloading the manifest.
+func (session *Session) uploadManifest(ctx context.Context, key string, manifest Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	standard := &uplink.StandardMetadata{
+		ContentType: "application/json",
+	}
+	custom := uplink.CustomMetadata{
+		metadataParts:    strconv.Itoa(len(manifest.Parts)),
+		metadataSize:     strconv.FormatInt(manifest.Size, 10),
+		metadataPartSize: strconv.FormatInt(manifest.PartSize, 10),
+		metadataSHA256:   manifest.SHA256,
+	}
+	return session.putObject(ctx, key, bytes.NewReader(data), func() (*uplink.StandardMetadata, uplink.CustomMetadata) {
+		return standard, custom
+	})
+}
+
+// deleteParts removes the uploaded parts of a failed parted upload.
+func (session *Session) deleteParts(keys []string) {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, err := session.project.DeleteObject(context.Background(), session.Config.Bucket, key); err != nil {
+			session.log.Warn("Could not delete part", logging.F("key", key), logging.F("error", err))
+		}
+	}
+}
+
+// partedObject fills in the size and number of parts of a listed manifest object.
+func partedObject(object *Object) {
+	parts, err := strconv.Atoi(object.Metadata[metadataParts])
+	if err != nil || parts == 0 {
+		return
+	}
+	object.Parts = parts
+	if size, err := strconv.ParseInt(object.Metadata[metadataSize], 10, 64); err == nil {
+		object.Size = size
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package storj
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"storj.io/uplink"
+)
+
+// DefaultLinkSharingURL is the link sharing service used to build share URLs.
+const DefaultLinkSharingURL = "https://link.tardigradeshare.io"
+
+// ScopeInfo describes the restrictions of a serialized scope (access grant) created by this package.
+type ScopeInfo struct {
+	Satellite string
+	Bucket    string
+	// Prefix is the key prefix the scope is limited to, empty for the whole project.
+	Prefix string
+
+	Restricted      bool
+	DisallowReads   bool
+	DisallowWrites  bool
+	DisallowLists   bool
+	DisallowDeletes bool
+	NotBefore       time.Time
+	NotAfter        time.Time
+}
+
+// restrict records the permission and path prefix a scope was shared with.
+func (info *ScopeInfo) restrict(permission uplink.Permission, prefix string) {
+	info.Restricted = true
+	info.Prefix = strings.TrimPrefix(prefix, "/")
+	info.DisallowReads = !permission.AllowRead
+	info.DisallowWrites = !permission.AllowWrite
+	info.DisallowLists = !permission.AllowList
+	info.DisallowDeletes = !permission.AllowDelete
+}
+
+// Fingerprint identifies a serialized scope without revealing it.
+func Fingerprint(scope string) string {
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:])
+}
+
+// ShareOptions describes the access granted by a shared scope.
+type ShareOptions struct {
+	// Path is the object key or key prefix, relative to the bucket, the scope is limited to.
+	Path string
+	// ListOnly allows listing objects only. Otherwise objects can be listed and downloaded.
+	ListOnly bool
+	// NotBefore and NotAfter limit when the scope is valid, unless they are zero.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// Share creates a serialized scope that gives read-only or list-only access to
+// options.Path in the configured bucket, optionally limited in time.
+// With keyValue "key" the scope is derived from the API key and encryption passphrase,
+// otherwise from the configured access grant. Nothing is uploaded.
+func Share(ctx context.Context, configStorj ConfigStorj, keyValue string, options ShareOptions) (string, ScopeInfo, error) {
+	if !options.NotBefore.IsZero() && !options.NotAfter.IsZero() && !options.NotAfter.After(options.NotBefore) {
+		return "", ScopeInfo{}, fmt.Errorf("scope would expire (%s) before it becomes valid (%s)",
+			options.NotAfter.Format(time.RFC3339), options.NotBefore.Format(time.RFC3339))
+	}
+
+	access, err := configStorj.access(ctx, keyValue)
+	if err != nil {
+		return "", ScopeInfo{}, err
+	}
+
+	prefix := strings.TrimPrefix(options.Path, "/")
+	permission := uplink.Permission{
+		AllowRead: !options.ListOnly,
+		AllowList: true,
+	}
+	shared, err := access.Share(permission, uplink.SharePrefix{
+		Bucket: configStorj.Bucket,
+		Prefix: prefix,
+	})
+	if err != nil {
+		return "", ScopeInfo{}, err
+	}
+	shared, err = restrictTime(shared, options.NotBefore, options.NotAfter)
+	if err != nil {
+		return "", ScopeInfo{}, err
+	}
+	serialized, err := shared.Serialize()
+	if err != nil {
+		return "", ScopeInfo{}, err
+	}
+
+	info, err := scopeInfo(shared, configStorj.Bucket)
+	if err != nil {
+		return "", ScopeInfo{}, err
+	}
+	info.restrict(permission, prefix)
+	info.NotBefore = options.NotBefore
+	info.NotAfter = options.NotAfter
+	return serialized, info, nil
+}
+
+// ShareURL returns the link sharing URL for an object or prefix shared with a serialized scope.
+func ShareURL(linkSharingURL, scope, bucket, path string) string {
+	var escaped []string
+	for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		escaped = append(escaped, url.PathEscape(part))
+	}
+	return strings.TrimSuffix(linkSharingURL, "/") + "/" + scope + "/" + url.PathEscape(bucket) + "/" + strings.Join(escaped, "/")
+}
@@ -5,15 +5,19 @@ package storj
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strconv"
+	"time"
 
-	"storj.io/storj/lib/uplink"
-	"storj.io/storj/pkg/macaroon"
+	"storj.io/uplink"
+
+	"utropicmedia/cpanel_storj_interface/logging"
+	"utropicmedia/cpanel_storj_interface/ratelimit"
 )
 
 // ConfigStorj depicts keys to search for within the storj_config.json file.
@@ -27,9 +31,30 @@ type ConfigStorj struct {
 	DisallowReads        string `json:"disallowReads"`
 	DisallowWrites       string `json:"disallowWrites"`
 	DisallowDeletes      string `json:"disallowDeletes"`
+
+	// AccessGrant is the serialized access grant used unless the API key is requested.
+	// SerializedScope, the scope of the former uplink library, is converted to an access grant
+	// when AccessGrant is not set.
+	AccessGrant string `json:"accessGrant"`
+
+	// KeyTemplate lays out backups below UploadPath, e.g. "{host}/{account}/{yyyy}/{mm}/{type}/{filename}".
+	KeyTemplate string `json:"keyTemplate"`
+	// BucketPerAccount stores the backups of each cPanel account in its own bucket,
+	// named after Bucket and the account.
+	BucketPerAccount bool `json:"bucketPerAccount"`
+
+	// PartSizeMB splits file-backed backups larger than one part into parts of this size,
+	// which are uploaded in parallel. 0 uploads every backup as a single stream.
+	PartSizeMB int `json:"partSizeMB"`
+	// UploadConcurrency is the number of parts uploaded at the same time.
+	UploadConcurrency int `json:"uploadConcurrency"`
+
+	// Bandwidth limits the rate of uploads to Storj.
+	Bandwidth ratelimit.Config `json:"bandwidth"`
 }
 
 // LoadStorjConfiguration reads and parses the JSON file that contain Storj configuration information.
+// Unknown fields and invalid values are not reported; config.LoadStorj checks the file strictly.
 func LoadStorjConfiguration(fullFileName string) (ConfigStorj, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename.
 
 	var configStorj ConfigStorj
@@ -41,200 +66,324 @@ func LoadStorjConfiguration(fullFileName string) (ConfigStorj, error) { // fullF
 	defer fileHandle.Close()
 
 	jsonParser := json.NewDecoder(fileHandle)
-	jsonParser.Decode(&configStorj)
+	if err := jsonParser.Decode(&configStorj); err != nil {
+		return configStorj, fmt.Errorf("could not parse %s: %v", fullFileName, err)
+	}
 
 	// Display read information.
-	fmt.Println("\nRead Storj configuration from the ", fullFileName, " file")
-	fmt.Println("\nAPI Key\t\t: ", configStorj.APIKey)
-	fmt.Println("Satellite	: ", configStorj.Satellite)
-	fmt.Println("Bucket		: ", configStorj.Bucket)
-	fmt.Println("Upload Path\t: ", configStorj.UploadPath)
-	fmt.Println("Serialized Scope Key\t: ", configStorj.SerializedScope)
+	logger.Info("Read Storj configuration",
+		logging.F("file", fullFileName),
+		logging.F("apikey", logging.Redact(configStorj.APIKey)),
+		logging.F("satellite", configStorj.Satellite),
+		logging.F("bucket", configStorj.Bucket),
+		logging.F("uploadPath", configStorj.UploadPath),
+		logging.F("keyTemplate", configStorj.keyTemplate()),
+		logging.F("accessGrant", logging.Redact(configStorj.AccessGrant)),
+		logging.F("serializedScope", logging.Redact(configStorj.SerializedScope)))
 
 	return configStorj, nil
 }
 
-// ConnectStorjReadUploadData reads Storj configuration from given file,
-// connects to the desired Storj network.
-// It then reads data using io.Reader interface and
-// uploads it as object to the desired bucket.
-func ConnectStorjReadUploadData(fullFileName string, fileReader io.Reader, fileName string, keyValue string, restrict string) (string, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename
-	// fileReader is an io.Reader implementation that 'reads' desired data,
-	// which is to be uploaded to storj V3 network.
-	// fileName for adding file name in storj V3 filename.
-	// Read Storj bucket's configuration from an external file.
-	var scope string = ""
-	configStorj, err := LoadStorjConfiguration(fullFileName)
-	if err != nil {
-		log.Fatal("loadStorjConfiguration:", err)
-	}
+// Session is an open connection to the bucket of a Storj configuration.
+type Session struct {
+	Config ConfigStorj
+	// Scope is the serialized access grant derived from the API key, restricted if requested.
+	// It is empty when the session was opened with the configured access grant.
+	Scope string
+	// ScopeInfo describes the restrictions of Scope.
+	ScopeInfo ScopeInfo
+
+	project *uplink.Project
+	// bucketMissing is set by OpenSessionReadOnly when the bucket does not exist.
+	bucketMissing bool
+	limiter       *ratelimit.Limiter
+	log           *logging.Logger
+}
 
-	fmt.Println("\nCreating New Uplink...")
+// Object describes an object stored in the bucket.
+type Object struct {
+	Key      string
+	Size     int64
+	Created  time.Time
+	Metadata map[string]string
+	// Parts is the number of parts of an object uploaded in parts, 0 otherwise.
+	Parts int
+}
 
-	var cfg uplink.Config
-	// Configure the user agent
-	cfg.Volatile.UserAgent = "cPanel"
-	ctx := context.Background()
+// OpenSession connects to the Storj network and opens the configured bucket, creating it if needed.
+// With keyValue "key" an access grant is requested with the API key and encryption passphrase
+// of the configuration, and shared restricted by the configured permissions
+// when restrict is "restrict". Otherwise the configured access grant is used.
+func OpenSession(ctx context.Context, configStorj ConfigStorj, keyValue string, restrict string) (*Session, error) {
+	return openSession(ctx, configStorj, keyValue, restrict, true)
+}
 
-	uplinkstorj, err := uplink.NewUplink(ctx, &cfg)
+// OpenSessionReadOnly is like OpenSession, but does not create a missing bucket,
+// which the session then lists as empty. It is meant for dry runs.
+func OpenSessionReadOnly(ctx context.Context, configStorj ConfigStorj, keyValue string, restrict string) (*Session, error) {
+	return openSession(ctx, configStorj, keyValue, restrict, false)
+}
+
+// openSession opens a session, creating the bucket when create is set.
+func openSession(ctx context.Context, configStorj ConfigStorj, keyValue string, restrict string, create bool) (*Session, error) {
+	if err := configStorj.CheckKeyTemplate(); err != nil {
+		return nil, err
+	}
+	if configStorj.BucketPerAccount {
+		return nil, fmt.Errorf("bucketPerAccount is set, but no account was selected for bucket %q", configStorj.Bucket)
+	}
+
+	limiter, err := ratelimit.New(configStorj.Bandwidth)
 	if err != nil {
-		uplinkstorj.Close()
-		log.Fatal("Could not create new Uplink object:", err)
+		return nil, fmt.Errorf("bandwidth: %v", err)
+	}
+	session := &Session{
+		Config:  configStorj,
+		limiter: limiter,
+		log:     logger.With(logging.F("bucket", configStorj.Bucket)),
 	}
-	defer uplinkstorj.Close()
-	var serializedScope string
-	if keyValue == "key" {
 
-		fmt.Println("Parsing the API key...")
-		key, err := uplink.ParseAPIKey(configStorj.APIKey)
-		if err != nil {
-			uplinkstorj.Close()
-			log.Fatal("Could not parse API key:", err)
-		}
+	access, err := configStorj.access(ctx, keyValue)
+	if err != nil {
+		return nil, err
+	}
 
-		fmt.Println("Opening Project...")
-		proj, err := uplinkstorj.OpenProject(ctx, configStorj.Satellite, key)
-		if err != nil {
-			uplinkstorj.Close()
-			log.Fatal("Could not open project:", err)
+	if keyValue == "key" {
+		shared := access
+		if restrict == "restrict" {
+			shared, session.ScopeInfo, err = configStorj.restrictedAccess(access)
+		} else {
+			session.ScopeInfo, err = scopeInfo(access, configStorj.Bucket)
 		}
-		defer proj.Close()
-
-		encryptionKey, err := proj.SaltedKeyFromPassphrase(ctx, configStorj.EncryptionPassphrase)
 		if err != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			log.Fatal("Could not create encryption key:", err)
+			return nil, err
 		}
-
-		// Creating an encryption context.
-		access := uplink.NewEncryptionAccessWithDefaultKey(*encryptionKey)
-
-		// Serializing the parsed access, so as to compare with the original key.
-		serializedAccess, err := access.Serialize()
+		session.Scope, err = shared.Serialize()
 		if err != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			log.Fatal("Error Serialized key : ", err)
+			return nil, err
 		}
+	}
 
-		// Load the existing encryption access context
-		accessParse, err := uplink.ParseEncryptionAccess(serializedAccess)
-		if err != nil {
-			log.Fatal(err)
-		}
+	session.log.Info("Opening project")
+	session.project, err = uplinkConfig().OpenProject(ctx, access)
+	if err != nil {
+		return nil, fmt.Errorf("could not open project: %v", err)
+	}
 
-		if restrict == "restrict" {
-			disallowRead, _ := strconv.ParseBool(configStorj.DisallowReads)
-			disallowWrite, _ := strconv.ParseBool(configStorj.DisallowWrites)
-			disallowDelete, _ := strconv.ParseBool(configStorj.DisallowDeletes)
-			userAPIKey, err := key.Restrict(macaroon.Caveat{
-				DisallowReads:   disallowRead,
-				DisallowWrites:  disallowWrite,
-				DisallowDeletes: disallowDelete,
-			})
-			if err != nil {
-				log.Fatal(err)
-			}
-			userAPIKey, userAccess, err := accessParse.Restrict(userAPIKey,
-				uplink.EncryptionRestriction{
-					Bucket:     configStorj.Bucket,
-					PathPrefix: configStorj.UploadPath,
-				},
-			)
-			if err != nil {
-				log.Fatal(err)
-			}
-			userRestrictScope := &uplink.Scope{
-				SatelliteAddr:    configStorj.Satellite,
-				APIKey:           userAPIKey,
-				EncryptionAccess: userAccess,
-			}
-			serializedRestrictScope, err := userRestrictScope.Serialize()
-			if err != nil {
-				log.Fatal(err)
-			}
-			scope = serializedRestrictScope
+	session.log.Info("Opening bucket")
 
+	if create {
+		// Create the desired Bucket within the Project if it does not exist yet.
+		_, err = session.project.EnsureBucket(ctx, configStorj.Bucket)
+	} else {
+		_, err = session.project.StatBucket(ctx, configStorj.Bucket)
+		if uplink.ErrBucketNotFound.Has(err) {
+			session.bucketMissing, err = true, nil
 		}
-		userScope := &uplink.Scope{
-			SatelliteAddr:    configStorj.Satellite,
-			APIKey:           key,
-			EncryptionAccess: access,
-		}
-		serializedScope, err = userScope.Serialize()
-		if err != nil {
-			log.Fatal(err)
-		}
-		if restrict == "" {
-			scope = serializedScope
-		}
+	}
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("could not open bucket %q: %v", configStorj.Bucket, err)
+	}
 
-		proj.Close()
-		uplinkstorj.Close()
-	} else {
-		serializedScope = configStorj.SerializedScope
+	return session, nil
+}
+
+// BucketExists reports whether the bucket of the session exists; only a session opened
+// with OpenSessionReadOnly can lack its bucket.
+func (session *Session) BucketExists() bool {
+	return !session.bucketMissing
+}
 
+// Close closes the project of the session.
+func (session *Session) Close() error {
+	if session.project == nil {
+		return nil
 	}
-	parsedScope, err := uplink.ParseScope(serializedScope)
+	return session.project.Close()
+}
+
+// objectMetadata returns the metadata of an object once all of its data was written.
+type objectMetadata func() (*uplink.StandardMetadata, uplink.CustomMetadata)
+
+// putObject uploads data as the object with the given key and the metadata returned by metadata, if any.
+func (session *Session) putObject(ctx context.Context, key string, data io.Reader, metadata objectMetadata) error {
+	upload, err := session.project.UploadObject(ctx, session.Config.Bucket, key, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	if _, err := io.Copy(upload, data); err != nil {
+		_ = upload.Abort()
+		return err
+	}
+	// The metadata is stored when the upload is committed, so it can describe the data, e.g. by its hash.
+	if metadata != nil {
+		standard, custom := metadata()
+		if err := upload.SetMetadata(ctx, standard, custom); err != nil {
+			_ = upload.Abort()
+			return err
+		}
+	}
+	// A canceled upload must not leave a partial object behind, even when the data was complete.
+	if err := ctx.Err(); err != nil {
+		_ = upload.Abort()
+		return err
+	}
+	if err := upload.Commit(); err != nil {
+		return err
+	}
+	// Commit does not report failures of the final segment,
+	// so make sure the object was actually stored.
+	_, err = session.project.StatObject(ctx, session.Config.Bucket, key)
+	return err
+}
 
-	uplinkstorj, err = uplink.NewUplink(ctx, &cfg)
-	if err != nil {
-		log.Fatal("Could not create new Uplink object:", err)
+// ObjectKey returns the key under which a backup is stored in the bucket.
+func (session *Session) ObjectKey(fields KeyFields) string {
+	return session.Config.ObjectKey(fields)
+}
+
+// UploadResult describes an uploaded object.
+type UploadResult struct {
+	Key      string
+	Bytes    int64
+	SHA256   string
+	Duration time.Duration
+}
+
+// Upload reads data using io.Reader and uploads it as object with the given key to the bucket.
+// The returned result holds the key of the object and the size and SHA-256 hash of the data read,
+// which are stored with the object as well, so that downloads can be verified.
+// Files larger than the configured part size are uploaded in parallel parts, any other reader is
+// uploaded as a single stream. Each part is stored as an object of its own and key holds a JSON
+// Manifest listing them, so a backup uploaded in parts is restored with DownloadObject, see Manifest.
+// A parted upload holds UploadConcurrency parts in memory.
+func (session *Session) Upload(ctx context.Context, key string, fileReader io.Reader) (UploadResult, error) {
+	if source, size, ok := session.partedSource(fileReader); ok {
+		return session.uploadParts(ctx, key, source, size)
 	}
-	proj, err := uplinkstorj.OpenProject(ctx, parsedScope.SatelliteAddr, parsedScope.APIKey)
+
+	result := UploadResult{Key: key}
+	log := session.log.With(logging.F("key", key))
+
+	// Read data using io.Reader and upload it to Storj.
+	log.Info("Uploading object")
+
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(session.limiter.Reader(ctx, fileReader), hash)}
+	start := time.Now()
+
+	var sum string
+	err := session.putObject(ctx, result.Key, counter, func() (*uplink.StandardMetadata, uplink.CustomMetadata) {
+		sum = hex.EncodeToString(hash.Sum(nil))
+		return nil, uplink.CustomMetadata{
+			metadataSize:   strconv.FormatInt(counter.n, 10),
+			metadataSHA256: sum,
+		}
+	})
+	result.Duration = time.Since(start)
+	result.Bytes = counter.n
 	if err != nil {
-		uplinkstorj.Close()
-		proj.Close()
-		log.Fatal("Could not open project:", err)
+		log.Error("Could not upload object", logging.F("error", err), logging.F("duration", result.Duration))
+		return result, err
 	}
+	result.SHA256 = sum
 
-	fmt.Println("Opening Bucket: ", configStorj.Bucket)
+	log.Info("Uploaded object",
+		logging.F("bytes", result.Bytes),
+		logging.F("sha256", result.SHA256),
+		logging.F("duration", result.Duration))
+	return result, nil
+}
 
-	// Open up the desired Bucket within the Project.
-	bucket, err := proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
-	if err != nil {
-		fmt.Println("Could not open bucket", configStorj.Bucket, ":", err)
-		fmt.Println("Trying to create new bucket....")
-		_, err1 := proj.CreateBucket(ctx, configStorj.Bucket, nil)
-		if err1 != nil {
-			uplinkstorj.Close()
-			proj.Close()
-			bucket.Close()
-			fmt.Printf("Could not create bucket %q:", configStorj.Bucket)
-			log.Fatal(err1)
-		} else {
-			fmt.Println("Created Bucket", configStorj.Bucket)
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// List returns all objects below prefix, e.g. the upload path or the AccountPrefix of an account.
+// The prefix must be empty or end with a slash.
+func (session *Session) List(ctx context.Context, prefix string) ([]Object, error) {
+	if session.bucketMissing {
+		return nil, nil
+	}
+	var objects []Object
+	iterator := session.project.ListObjects(ctx, session.Config.Bucket, &uplink.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+		Info:      true,
+		Standard:  true,
+		Custom:    true,
+	})
+	for iterator.Next() {
+		item := iterator.Item()
+		if item.IsPrefix || IsPartKey(item.Key) {
+			continue
 		}
-		fmt.Println("Opening created Bucket: ", configStorj.Bucket)
-		bucket, err = proj.OpenBucket(ctx, configStorj.Bucket, parsedScope.EncryptionAccess)
-		if err != nil {
-			fmt.Printf("Could not open bucket %q: %s", configStorj.Bucket, err)
+		object := Object{
+			Key:      item.Key,
+			Size:     item.Standard.ContentLength,
+			Created:  item.Info.Created,
+			Metadata: item.Custom,
 		}
+		partedObject(&object)
+		objects = append(objects, object)
 	}
+	return objects, iterator.Err()
+}
 
-	defer bucket.Close()
+// Delete removes an object listed by List from the bucket, including its parts.
+func (session *Session) Delete(ctx context.Context, object Object) error {
+	if _, err := session.project.DeleteObject(ctx, session.Config.Bucket, object.Key); err != nil {
+		return err
+	}
+	for n := 1; n <= object.Parts; n++ {
+		if _, err := session.project.DeleteObject(ctx, session.Config.Bucket, PartKey(object.Key, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	checkSlash := configStorj.UploadPath[len(configStorj.UploadPath)-1:]
-	if checkSlash != "/" {
-		configStorj.UploadPath = configStorj.UploadPath + "/"
+// ConnectStorjReadUploadData reads Storj configuration from given file,
+// connects to the desired Storj network.
+// It then reads data using io.Reader interface and
+// uploads it as object to the desired bucket.
+func ConnectStorjReadUploadData(fullFileName string, fileReader io.Reader, fileName string, keyValue string, restrict string) (string, error) { // fullFileName for fetching storj V3 credentials from  given JSON filename
+	// fileReader is an io.Reader implementation that 'reads' desired data,
+	// which is to be uploaded to storj V3 network.
+	// fileName for adding file name in storj V3 filename.
+	// Read Storj bucket's configuration from an external file.
+	configStorj, err := LoadStorjConfiguration(fullFileName)
+	if err != nil {
+		return "", err
 	}
+	return ConnectStorjReadUploadDataWithConfig(configStorj, fileReader, fileName, keyValue, restrict)
+}
 
-	// Read data using io.Reader and upload it to Storj.
-	fmt.Println("File path: ", configStorj.UploadPath+fileName)
-	fmt.Println("\nUploading of the object to the Storj bucket: Initiated...")
+// ConnectStorjReadUploadDataWithConfig is like ConnectStorjReadUploadData for an already loaded configuration,
+// e.g. one read with config.LoadStorj.
+func ConnectStorjReadUploadDataWithConfig(configStorj ConfigStorj, fileReader io.Reader, fileName string, keyValue string, restrict string) (string, error) {
+	// Data uploaded here does not belong to a cPanel account,
+	// so it goes to the configured bucket even with BucketPerAccount.
+	configStorj.BucketPerAccount = false
 
-	err = bucket.UploadObject(ctx, configStorj.UploadPath+fileName, fileReader, nil)
+	ctx := context.Background()
 
+	session, err := OpenSession(ctx, configStorj, keyValue, restrict)
 	if err != nil {
-		fmt.Printf("Could not upload: %s\t", err)
-		return scope, err
+		return "", err
 	}
+	defer session.Close()
 
-	fmt.Println("Uploading of the object to the Storj bucket: Completed!")
-
-	return scope, nil
+	key := session.ObjectKey(KeyFields{FileName: fileName, Time: time.Now()})
+	_, err = session.Upload(ctx, key, fileReader)
+	return session.Scope, err
 }